@@ -3,6 +3,7 @@ package generic
 import (
 	"errors"
 	"log"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -39,6 +40,9 @@ func ConvertDDMMSSSSLatitudeToFloat(lat string) (float32, error) {
 
 	//extract the seconds
 	lat = lat[0:p] //remove the last char
+	if len(strings.Split(lat, ".")[0]) < 5 {
+		return 0.0, errors.New(lat + " Not a valid format DDMMSS.SS{N|S}")
+	}
 	if strings.Contains(lat, ".") {
 		p := strings.Index(lat, ".") - 2 //get two digit before the 2
 		secs := lat[p:]
@@ -110,6 +114,9 @@ func ConvertDDDMMSSSSLongitudeToFloat(long string) (float32, error) {
 
 	//extract the seconds
 	long = long[0:p] //remove the last char
+	if len(strings.Split(long, ".")[0]) < 5 {
+		return 0.0, errors.New(long + " Not a valid format DDDMMSS.SS{E|W}")
+	}
 	if strings.Contains(long, ".") {
 		p := strings.Index(long, ".") - 2 //get two digit before the 2
 		secs := long[p:]
@@ -157,3 +164,44 @@ func ConvertDDDMMSSSSLongitudeToFloat(long string) (float32, error) {
 	}
 
 }
+
+const earthRadiusNM = 3440.065
+
+/*
+	Get the great circle distance between two positions, in nautical miles.
+*/
+func (p GeoPosition) DistanceTo(q GeoPosition) float32 {
+	lat1 := float64(p.Latitude) * math.Pi / 180
+	lat2 := float64(q.Latitude) * math.Pi / 180
+	dLat := lat2 - lat1
+	dLong := float64(q.Longitude-p.Longitude) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return float32(2 * earthRadiusNM * math.Asin(math.Sqrt(a)))
+}
+
+/*
+	Get the initial true bearing (great circle) from the position to the indicated position, in degrees.
+*/
+func (p GeoPosition) BearingTo(q GeoPosition) float32 {
+	lat1 := float64(p.Latitude) * math.Pi / 180
+	lat2 := float64(q.Latitude) * math.Pi / 180
+	dLong := float64(q.Longitude-p.Longitude) * math.Pi / 180
+	y := math.Sin(dLong) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLong)
+	brg := math.Atan2(y, x) * 180 / math.Pi
+	return float32(math.Mod(brg+360, 360))
+}
+
+/*
+	Get the position at the indicated distance (in nautical miles) and true bearing (in degrees)
+	of the position, along a great circle.
+*/
+func (p GeoPosition) Destination(bearing float32, distance float32) GeoPosition {
+	lat1 := float64(p.Latitude) * math.Pi / 180
+	long1 := float64(p.Longitude) * math.Pi / 180
+	brg := float64(bearing) * math.Pi / 180
+	d := float64(distance) / earthRadiusNM
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(d) + math.Cos(lat1)*math.Sin(d)*math.Cos(brg))
+	long2 := long1 + math.Atan2(math.Sin(brg)*math.Sin(d)*math.Cos(lat1), math.Cos(d)-math.Sin(lat1)*math.Sin(lat2))
+	return GeoPosition{Latitude: float32(lat2 * 180 / math.Pi), Longitude: float32(long2 * 180 / math.Pi)}
+}
@@ -3,11 +3,16 @@ package generic
 import (
 	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 	"sync"
 )
 
+// The types of airport
+const (
+	AerodromeType = "Aerodrome"
+	HeliportType  = "Heliport"
+)
+
 /*
  The Airport Type contains the information for the definition of an airport in the AIP.
  The ICAO code is the main mean of identification of the airport.
@@ -15,26 +20,31 @@ import (
  In order to manage the downloads, the structure contains information about the status of the downloads.
  A waiting group is associated to the Airport structure in order to manage the downloads
  or any other tasks associated to the airport.
+ The AirportType distinguishes the aerodromes (AD 2) from the heliports (AD 3).
 */
 type Airport struct {
 	Title       string
 	Icao        string
 	Link        string `json:"-"`
-	AirportType string `json:"-"`
+	AirportType string
 	DownloadData
 	AdminData   AdminData
 	Navaids     map[string]Navaid
 	PdfData     []PdfData    `json:"-"`
 	MergePdf    []MergedData `json:"-"`
 	Com         []ComData
+	Runways     []Runway
+	Airspaces   []Airspace
+	Obstacles   []Obstacle
 	//Airport     IAirport `json:"-"`
 	AipDocument IAipDocument     `json:"-"`
 	HtmlPage    string           `json:"-"`
+	Errors      []error          `json:"-"`
 }
 
 type IAirport interface {
-	GetPDFFromHTML(cl *http.Client, aipURLDir string)
-	DownloadPage(cl *http.Client)
+	GetPDFFromHTML(cl *http.Client, aipURLDir string) error
+	DownloadPage(cl *http.Client) error
 	GetNavaids() (map[string]Navaid, int)
 }
 
@@ -42,40 +52,65 @@ type DownloadData struct {
 	DownloadCount int
 	Wg            sync.WaitGroup
 	NbDownloaded  int
+	nbMu          sync.Mutex
+}
+
+/*
+	Count a downloaded file and get the number of files downloaded.
+	The files are counted by the download workers and by the producer of the jobs at the same time.
+*/
+func (d *DownloadData) AddDownloaded() int {
+	d.nbMu.Lock()
+	defer d.nbMu.Unlock()
+	d.NbDownloaded++
+	return d.NbDownloaded
 }
 
 /*
 AdminData contains the admnistrative information of the airport.
 Basic information are related to the ARP coordinates, elevation, magnetic variations,...
+The magnetic variations are in degrees, positive to the east.
+The altitude of the ARP is the aerodrome elevation in feet.
 */
 type AdminData struct {
-	ArpCoord         string
-	Elevation        string
-	Mag_var          string
-	Mag_annualchange string
-	Geoid_undulation string
-	Traffic_types    string
+	ArpCoord         GeoPosition
+	ArpSite          string
+	Elevation        Measure
+	Mag_var          float32
+	Mag_annualchange float32
+	Geoid_undulation Measure
+	Traffic_types    []string
 }
 
 /*
  ComData describes the communication means available on the airport.
+ A service can use several frequencies, each one is kept with its unit (MHZ, KHZ).
 */
 type ComData struct {
-	service         string
-	frequency       string
-	callSign        string
-	operationsHours string
-	remarks         string
+	Service         string
+	CallSign        string
+	Frequencies     []Measure
+	OperationsHours string
+	Remarks         string
 }
 
+/*
+ A PdfData is a PDF file of the airport: the text of the airport part, or a chart.
+ The Title is the human title of the chart as published in the chart list.
+ The Category, the Procedure and the Runways are given by the classification of the chart (see Classify).
+*/
 type PdfData struct {
 	ParentAirport   *Airport
 	Title           string
 	DataContentType string
+	Category        string
+	Procedure       string
+	Runways         []string
 	Link            string
 	FileName        string
 	FilePath        string
 	DownloadStatus  bool
+	Err             error
 }
 
 type MergedData struct {
@@ -93,6 +128,14 @@ func (a *Airport) DirDownload() string {
 	return filepath.Join(a.AipDocument.DirMainDownload(), a.Icao)
 }
 
+/*
+	Record an error of the airport. The errors are reported at the end of the run.
+*/
+func (a *Airport) AddError(err error) {
+	log.Printf("Airport %s - %s \n", a.Icao, err)
+	a.Errors = append(a.Errors, err)
+}
+
 func (a *Airport) AddPdfData(pdf PdfData)  {
 	pdf.FilePath = filepath.Join(a.DirDownload(), pdf.FileName)
 	a.PdfData = append(a.PdfData, pdf)
@@ -105,8 +148,8 @@ Also, add each PdfData file as a task in the Waiting group of the Airport
 func (a *Airport) SetPdfDataListInChannel(jobs *chan *PdfData) {
 	for i := range a.PdfData {
 		a.PdfData[i].ParentAirport = a
-		*jobs <- &(a.PdfData[i])
 		a.Wg.Add(1) //add to the working group
+		*jobs <- &(a.PdfData[i])
 	}
 }
 
@@ -128,34 +171,23 @@ func (a *Airport) DetermmineIsDownloaded() bool {
 /*
 	Download the airport page in a synchronous way.
 */
-func  DownloadAirportPageSync(cl *http.Client, docWg *sync.WaitGroup, a IAirport) {
-	go a.DownloadPage(cl)
-	docWg.Done()
+func  DownloadAirportPageSync(cl *http.Client, docWg *sync.WaitGroup, a IAirport) error {
+	defer docWg.Done()
+	return a.DownloadPage(cl)
 }
 
 /*
 	Determine if the airport web page shall be downloaded.
 	Return true if the page shall be downloaded.
+	The local copy is kept if the manifest of the edition confirms it has been downloaded
+	from the same url, has the same size than the body announced by the server, and its
+	content is unchanged (SHA-256).
 	By default, we download the page.
 */
-func (apt *Airport) ShouldIDownloadHtmlPage(realPath string, bodySize int64) bool {
-	if st, err := os.Stat(realPath); err == nil {
-		//The file exists, check the date of the file
-	
-		if st.ModTime().After(apt.AipDocument.Document().EffectiveDate) && st.ModTime().Before(apt.AipDocument.Document().NextEffectiveDate) {
-			if bodySize == st.Size() {
-				return false
-			}
-		}
-		return true
-
-	} else if os.IsNotExist(err) {
-		return true
-	} else {
-		// Schrodinger: file may or may not exist. See err for details.
-		// Therefore, do *NOT* use !os.IsNotExist(err) to test for file existence
-		log.Printf("File %s is not writeable or readable \n", realPath)
+func (apt *Airport) ShouldIDownloadHtmlPage(realPath string, url string, bodySize int64) bool {
+	entry, ok := apt.AipDocument.Manifest().Lookup(realPath)
+	if !ok || entry.Url != url || entry.Size != bodySize {
 		return true
 	}
-	return true
+	return !entry.Matches(realPath)
 }
@@ -2,11 +2,18 @@ package generic
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"path/filepath"
+	"sync"
 	"time"
 )
 
+/*
+ An AipDocument is an edition of the AIP of a country.
+ The en-route data are stored by the Get functions of IAipDocument, so they are part of the edition report and of the exports.
+ The items which cannot be converted are recorded in the Errors of the document.
+*/
 type AipDocument struct {
 	IsActive          bool
 	EffectiveDate     time.Time
@@ -20,16 +27,33 @@ type AipDocument struct {
 	FullURLPage       string
 	Airports          []Airport
 	Navaids			  []Navaid
+	Waypoints         []Waypoint
+	Routes            []Route
+	Airspaces         []Airspace
+	RestrictedAreas   []RestrictedArea
+	Obstacles         []Obstacle
 	CountryCode       string
+	Errors            []error `json:"-"`
+	manifest          *Manifest
 }
 
 type IAipDocument interface {
-	LoadAirports(cl *http.Client) 
-	GetNavaids(cl *http.Client) []Navaid
-	DownloadAllAiportsData(client *http.Client)
+	LoadAirports(cl *http.Client) error
+	GetNavaids(cl *http.Client) ([]Navaid, error)
+	GetWaypoints(cl *http.Client) ([]Waypoint, error)
+	GetRoutes(cl *http.Client) ([]Route, error)
+	GetAirspaces(cl *http.Client) ([]Airspace, error)
+	GetRestrictedAreas(cl *http.Client) ([]RestrictedArea, error)
+	GetObstacles(cl *http.Client) ([]Obstacle, error)
+	DownloadAllAiportsData(client *http.Client, merge bool)
 	DownloadAllAiportsHtmlPage(cl *http.Client)
+	MergeAllAiportsData()
+	VerifyAllAiportsData() []error
+	AirportsList() []*Airport
 	DirMainDownload() string
 	DirMergeFiles() string
+	Manifest() *Manifest
+	AddError(err error)
 	Document() AipDocument
 }
 
@@ -44,6 +68,35 @@ func (aip *AipDocument) DirMergeFiles() string {
 	return filepath.Join(aip.DirMainDownload(), ConfData.MergeDir)
 }
 
+var manifestMu sync.Mutex
+
+/*
+	Get the manifest of the edition directory.
+	The manifest is loaded at the first call, so the effective date shall be known.
+	An unreadable manifest is replaced by an empty one: all the files will be downloaded again.
+*/
+func (aip *AipDocument) Manifest() *Manifest {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	if aip.manifest == nil {
+		m, err := LoadManifest(aip.DirMainDownload())
+		if err != nil {
+			log.Printf("Manifest of %s disregarded: %s \n", aip.DirMainDownload(), err)
+		}
+		aip.manifest = m
+	}
+	return aip.manifest
+}
+
+/*
+	Record an error which is not attached to an airport.
+	The errors are reported at the end of the run.
+*/
+func (aip *AipDocument) AddError(err error) {
+	log.Println(err)
+	aip.Errors = append(aip.Errors, err)
+}
+
 func (aip *AipDocument) Document() AipDocument {
 	return *aip
 }
@@ -1,6 +1,5 @@
 package generic
 
-
 import (
 	"encoding/json"
 	"fmt"
@@ -9,34 +8,101 @@ import (
 	"os"
 )
 
+/*
+CountryAis is the interface implemented by each country provider.
+Process runs the full pipeline (editions, navaids, airports, download and merge).
+It returns an error when the pipeline cannot run, and the report of the failures
+of the individual airports and files otherwise.
+Editions and ActiveDocument give access to the individual steps, so the
+command line can run only the stage required.
+*/
 type CountryAis interface {
-	LoadJsonFile(path string)
-	initClient() http.Client
-	Process()
+	LoadJsonFile(path string) error
+	InitClient() (http.Client, error)
+	Process() (*FailureReport, error)
+	Editions(cl *http.Client) ([]AipDocument, error)
+	ActiveDocument(cl *http.Client) (IAipDocument, error)
+}
+
+/*
+CountryConfig identifies a country to process and the configuration file
+used by its provider (login data, AIP pages,...).
+The name shall match the name used by the provider during its registration.
+*/
+type CountryConfig struct {
+	Name       string `json:"name"`
+	ConfigFile string `json:"configFile"`
 }
 
 type ConfigurationDataStruct struct {
 	MainLocalDir string
 	MergeDir     string
+	Countries    []CountryConfig `json:"countries"`
+	// Maximum number of failures accepted before the run is considered as failed.
+	// A negative value accepts any number of failures.
+	MaxFailures int `json:"maxFailures"`
+	// Retry policy of the HTTP requests
+	Retry RetryPolicy `json:"retry"`
+	// Number of concurrent PDF downloads (default 5)
+	DownloadWorkers int `json:"downloadWorkers"`
+	// Number of airport pages retrieved concurrently (default 5)
+	PageWorkers int `json:"pageWorkers"`
+	// Maximum number of requests per second sent to a host, 0 for no limit
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	// HTTP mode: "" (live), "record" or "replay", see NewTransport
+	HttpMode string `json:"httpMode"`
+	// Archive directory of the record and replay modes
+	HttpArchiveDir string `json:"httpArchiveDir"`
+	// Merged files produced for each airport, see MergeProfile (default: full and chart files)
+	MergeProfiles []MergeProfile `json:"mergeProfiles"`
+}
+
+const defaultWorkers = 5
+
+/*
+	Number of concurrent PDF downloads.
+*/
+func (cds *ConfigurationDataStruct) DownloadWorkerCount() int {
+	if cds.DownloadWorkers > 0 {
+		return cds.DownloadWorkers
+	}
+	return defaultWorkers
+}
+
+/*
+	Number of airport pages retrieved concurrently.
+*/
+func (cds *ConfigurationDataStruct) PageWorkerCount() int {
+	if cds.PageWorkers > 0 {
+		return cds.PageWorkers
+	}
+	return defaultWorkers
 }
 
 var ConfData ConfigurationDataStruct
 
-func (cds *ConfigurationDataStruct) LoadConfigurationFile(path string) {
+func (cds *ConfigurationDataStruct) LoadConfigurationFile(path string) error {
 	// Open our jsonFile
 	jsonFile, err := os.Open(path)
 	// if we os.Open returns an error then handle it
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
 	// defer the closing of our jsonFile so that we can parse it later on
 	defer jsonFile.Close()
 
 	byteValue, err := ioutil.ReadAll(jsonFile)
+	if err != nil {
+		return err
+	}
 
 	err = json.Unmarshal(byteValue, cds)
 	if err != nil {
-		fmt.Println("error:", err)
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	if err := cds.CheckMergeProfiles(); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
 	}
+	return nil
 }
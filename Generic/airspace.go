@@ -0,0 +1,227 @@
+package generic
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+ An Airspace is a volume of the airspace structure: FIR, UTA, CTA, TMA (ENR 2.1),
+ or the CTR or ATZ of an aerodrome (AD 2.17).
+ The lateral boundary is a closed polygon; the arcs and the circles are approximated
+ by points every 5 degrees, the segments along a border are approximated by straight lines.
+ The controlling unit is the unit providing the service, with its callsign and frequencies.
+ The language and the transition altitude are only known for the aerodrome airspaces.
+*/
+type Airspace struct {
+	Name               string
+	Type               string
+	Class              string
+	Boundary           []GeoPosition
+	Upper              VerticalLimit
+	Lower              VerticalLimit
+	Unit               string
+	CallSign           string
+	Frequencies        []Measure
+	Language           string
+	TransitionAltitude VerticalLimit
+	Remarks            string
+}
+
+/*
+ A RestrictedArea is a prohibited (P), restricted (R) or danger (D) area (ENR 5.1).
+ The lateral boundary is a closed polygon, approximated as for an Airspace.
+ The Centre and the Radius (in nautical miles) are only known for a circular area.
+*/
+type RestrictedArea struct {
+	Id            string
+	Type          string
+	Name          string
+	Boundary      []GeoPosition
+	Centre        *GeoPosition
+	Radius        float32
+	Upper         VerticalLimit
+	Lower         VerticalLimit
+	ActivityHours string
+	Remarks       string
+}
+
+// the step of the approximation of the arcs and the circles, in degrees
+const arcStep = 5
+
+var (
+	coordPairRe = regexp.MustCompile(`([0-9]{6}(?:\.[0-9]+)?[NS])\s*([0-9]{7}(?:\.[0-9]+)?[EW])`)
+	radiusRe    = regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)\s*(NM|KM|M)\b`)
+	boundarySep = regexp.MustCompile(`\s+-\s+|\s*–\s*`)
+	arcRe       = regexp.MustCompile(`\bARC\b`)
+)
+
+/*
+	Parse the lateral limits of an airspace, as published in the AIP. Exemples:
+	  342000N 1393000E - 343000N 1400000E - 340000N 1400000E - 342000N 1393000E
+	  A circle, radius 5 NM, centred on 353312N 1394652E
+	  ... - thence clockwise along the arc of a circle of 10 NM radius centred on 350000N 1400000E to 345000N 1393000E - ...
+	  ... - along the Japan/Russia FIR boundary to 450000N 1450000E - ...
+	The returned polygon is closed (the last point is the first one).
+*/
+func ParseLateralLimits(t string) ([]GeoPosition, error) {
+	var polygon []GeoPosition
+	for _, part := range boundarySep.Split(t, -1) {
+		up := strings.ToUpper(part)
+		pairs := coordPairRe.FindAllStringSubmatchIndex(part, -1)
+		var points []GeoPosition
+		for _, p := range pairs {
+			pos, err := parseCoordPair(part[p[2]:p[3]], part[p[4]:p[5]])
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, pos)
+		}
+
+		switch {
+		case arcRe.MatchString(up):
+			//the centre follows "centred on", the end point is the other coordinates
+			centreLoc := strings.Index(up, "CENT")
+			arcLoc := arcRe.FindStringIndex(up)[0]
+			radius, err := ParseRadius(up)
+			if err != nil {
+				return nil, err
+			}
+			centre, end := -1, -1
+			for i, p := range pairs {
+				switch {
+				case centre < 0 && centreLoc >= 0 && p[0] > centreLoc:
+					centre = i
+				case p[0] < arcLoc:
+					//a point before the arc
+					polygon = append(polygon, points[i])
+				default:
+					end = i
+				}
+			}
+			if centre < 0 || end < 0 || len(polygon) == 0 {
+				return nil, errors.New(strings.TrimSpace(part) + " Not a valid arc")
+			}
+			clockwise := !strings.Contains(up, "COUNTER") && !strings.Contains(up, "ANTI")
+			polygon = append(polygon, arc(points[centre], radius, polygon[len(polygon)-1], points[end], clockwise)...)
+		case strings.Contains(up, "CIRCLE"):
+			centre, radius, err := ParseCircle(part)
+			if err != nil {
+				return nil, err
+			}
+			polygon = append(polygon, Circle(centre, radius)...)
+		default:
+			//the points, including the end of a segment along a border
+			polygon = append(polygon, points...)
+		}
+	}
+
+	if len(polygon) < 3 {
+		return nil, errors.New(strings.TrimSpace(t) + " Not a valid lateral limit")
+	}
+	if polygon[0] != polygon[len(polygon)-1] {
+		polygon = append(polygon, polygon[0])
+	}
+	return polygon, nil
+}
+
+/*
+	Parse a circle (ex: A circle, radius 5 NM, centred on 353312N 1394652E).
+	The radius is in nautical miles.
+*/
+func ParseCircle(t string) (GeoPosition, float32, error) {
+	up := strings.ToUpper(t)
+	pair := coordPairRe.FindStringSubmatch(up)
+	if !strings.Contains(up, "CIRCLE") || pair == nil {
+		return GeoPosition{}, 0, errors.New(strings.TrimSpace(t) + " Not a valid circle")
+	}
+	radius, err := ParseRadius(up)
+	if err != nil {
+		return GeoPosition{}, 0, err
+	}
+	centre, err := parseCoordPair(pair[1], pair[2])
+	if err != nil {
+		return GeoPosition{}, 0, err
+	}
+	return centre, radius, nil
+}
+
+/*
+	Get the closed polygon approximating a circle (radius in nautical miles).
+*/
+func Circle(centre GeoPosition, radius float32) []GeoPosition {
+	var points []GeoPosition
+	for brg := 0; brg < 360; brg += arcStep {
+		points = append(points, centre.Destination(float32(brg), radius))
+	}
+	return append(points, points[0])
+}
+
+// arc provides the points of the arc from the start point (excluded) to the end point (included).
+func arc(centre GeoPosition, radius float32, start GeoPosition, end GeoPosition, clockwise bool) []GeoPosition {
+	startBrg := centre.BearingTo(start)
+	endBrg := centre.BearingTo(end)
+	sweep := endBrg - startBrg
+	if !clockwise {
+		sweep = -sweep
+	}
+	for sweep <= 0 {
+		sweep += 360
+	}
+
+	var points []GeoPosition
+	for a := float32(arcStep); a < sweep; a += arcStep {
+		brg := startBrg + a
+		if !clockwise {
+			brg = startBrg - a
+		}
+		points = append(points, centre.Destination(brg, radius))
+	}
+	return append(points, end)
+}
+
+/*
+	Parse the first coordinates of the text (ex: "353312N 1394652E").
+*/
+func ParseGeoPosition(t string) (GeoPosition, error) {
+	pair := coordPairRe.FindStringSubmatch(strings.ToUpper(t))
+	if pair == nil {
+		return GeoPosition{}, errors.New(strings.TrimSpace(t) + " Not a valid format DDMMSS{N|S} DDDMMSS{E|W}")
+	}
+	return parseCoordPair(pair[1], pair[2])
+}
+
+func parseCoordPair(lat string, long string) (GeoPosition, error) {
+	la, err := ConvertDDMMSSSSLatitudeToFloat(lat)
+	if err != nil {
+		return GeoPosition{}, err
+	}
+	lo, err := ConvertDDDMMSSSSLongitudeToFloat(long)
+	if err != nil {
+		return GeoPosition{}, err
+	}
+	return GeoPosition{Latitude: la, Longitude: lo}, nil
+}
+
+/*
+	Get the radius of the text in nautical miles (ex: "radius 5 NM", "9 KM radius").
+*/
+func ParseRadius(t string) (float32, error) {
+	m := radiusRe.FindStringSubmatch(t)
+	if m == nil {
+		return 0, errors.New(strings.TrimSpace(t) + " No radius")
+	}
+	v, err := strconv.ParseFloat(m[1], 32)
+	if err != nil {
+		return 0, err
+	}
+	switch m[2] {
+	case "KM":
+		v = v / 1.852
+	case "M":
+		v = v / 1852
+	}
+	return float32(v), nil
+}
@@ -0,0 +1,53 @@
+package generic
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/*
+	Write a file atomically.
+	The content is written by the write function in a temporary file of the target directory.
+	The temporary file is synced on disk, checked by the verify function (if not nil),
+	and then renamed into place. In case of error or interruption, the target file
+	is left unchanged: a partially written file is never visible under the target name.
+*/
+func WriteFileAtomic(path string, write func(w io.Writer) error, verify func(tmpPath string) error) (err error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	if verify != nil {
+		if err = verify(tmpPath); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, path)
+}
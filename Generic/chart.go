@@ -0,0 +1,190 @@
+package generic
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The categories of charts (AD 2.24 and AD 3.23)
+const (
+	ChartADC     = "ADC"     //aerodrome or heliport chart
+	ChartPDC     = "PDC"     //aircraft parking/docking chart
+	ChartGMC     = "GMC"     //aerodrome ground movement chart
+	ChartAOC     = "AOC"     //aerodrome obstacle chart
+	ChartPATC    = "PATC"    //precision approach terrain chart
+	ChartATCSMAC = "ATCSMAC" //ATC surveillance minimum altitude chart
+	ChartSID     = "SID"     //standard departure chart
+	ChartSTAR    = "STAR"    //standard arrival chart
+	ChartIAC     = "IAC"     //instrument approach chart
+	ChartVAC     = "VAC"     //visual approach chart
+	ChartArea    = "AREA"    //area chart
+	ChartOther   = "OTHER"
+)
+
+// chartCategoryTitles gives the human title of each category of charts
+var chartCategoryTitles = map[string]string{
+	ChartADC:     "Aerodrome Charts",
+	ChartPDC:     "Aircraft Parking/Docking Charts",
+	ChartGMC:     "Ground Movement Charts",
+	ChartAOC:     "Aerodrome Obstacle Charts",
+	ChartPATC:    "Precision Approach Terrain Charts",
+	ChartATCSMAC: "ATC Surveillance Minimum Altitude Charts",
+	ChartSID:     "Standard Departure Charts",
+	ChartSTAR:    "Standard Arrival Charts",
+	ChartIAC:     "Instrument Approach Charts",
+	ChartVAC:     "Visual Approach Charts",
+	ChartArea:    "Area Charts",
+	ChartOther:   "Other Charts",
+}
+
+/*
+	Get the human title of a category of charts (ex: IAC gives Instrument Approach Charts).
+	An unknown category is its own title.
+*/
+func ChartCategoryTitle(category string) string {
+	if t, ok := chartCategoryTitles[category]; ok {
+		return t
+	}
+	return category
+}
+
+// chartCategories identifies the category of a chart by its title.
+// The order matters: an aerodrome obstacle chart is not an aerodrome chart,
+// a visual approach chart is not an instrument approach chart.
+var chartCategories = []struct {
+	category string
+	re       *regexp.Regexp
+}{
+	{ChartAOC, regexp.MustCompile(`OBSTACLE CHART|\bAOC\b`)},
+	{ChartPATC, regexp.MustCompile(`PRECISION APPROACH TERRAIN|\bPATC\b`)},
+	{ChartPDC, regexp.MustCompile(`PARKING|DOCKING|\bPDC\b`)},
+	{ChartGMC, regexp.MustCompile(`GROUND MOVEMENT|\bGMC\b`)},
+	{ChartADC, regexp.MustCompile(`AERODROME CHART|HELIPORT CHART|\bADC\b`)},
+	{ChartATCSMAC, regexp.MustCompile(`MINIMUM ALTITUDE|\bATCSMAC\b`)},
+	{ChartSID, regexp.MustCompile(`DEPARTURE|\bSID\b`)},
+	{ChartSTAR, regexp.MustCompile(`ARRIVAL|\bSTAR\b`)},
+	{ChartVAC, regexp.MustCompile(`VISUAL APPROACH|\bVAC\b`)},
+	{ChartIAC, regexp.MustCompile(`INSTRUMENT APPROACH|\bIAC\b|\b(?:ILS|LOC|LDA|VOR|NDB|TACAN|RNAV|RNP|GLS|GPS)\b.*\bRWY`)},
+	{ChartArea, regexp.MustCompile(`AREA CHART`)},
+}
+
+var (
+	approachProcedureRe = regexp.MustCompile(`\b(?:ILS|LOC|LDA|VOR|NDB|TACAN|RNAV|RNP|GLS|GPS|VISUAL)\b.*?\bRWY\s*[0-9]{2}[LRC]?`)
+	namedProcedureRe    = regexp.MustCompile(`\b[A-Z]{3,5}\s*(?:[0-9][A-Z]?|ONE|TWO|THREE|FOUR|FIVE|SIX|SEVEN|EIGHT|NINE)\s+(?:DEPARTURE|ARRIVAL)\b`)
+	chartRunwaysRe      = regexp.MustCompile(`\bRWY\s*([0-9]{2}[LRC]?(?:\s*/\s*[0-9]{2}[LRC]?)*)`)
+)
+
+/*
+	Get the category of a chart (ex: IAC) from its title, ChartOther if unknown.
+*/
+func ChartCategory(title string) string {
+	up := strings.ToUpper(title)
+	for _, c := range chartCategories {
+		if c.re.MatchString(up) {
+			return c.category
+		}
+	}
+	return ChartOther
+}
+
+/*
+	Classify the chart with its title and the label of its row in the chart list
+	(ex: "Instrument Approach Chart" and "ILS Z RWY34R").
+	The category is given by the label, else by the title. The procedure
+	(ex: ILS Z RWY34R, LAXAS ONE DEPARTURE) and the runways are given by the title.
+*/
+func (p *PdfData) Classify(label string) {
+	p.Category = ChartCategory(label)
+	if p.Category == ChartOther {
+		p.Category = ChartCategory(p.Title)
+	}
+
+	up := strings.ToUpper(p.Title)
+	p.Procedure = approachProcedureRe.FindString(up)
+	if p.Procedure == "" {
+		p.Procedure = namedProcedureRe.FindString(up)
+	}
+
+	p.Runways = nil
+	for _, m := range chartRunwaysRe.FindAllStringSubmatch(up, -1) {
+		for _, r := range strings.Split(m[1], "/") {
+			if d, ok := RunwayDesignator(r); ok && !containsString(p.Runways, d) {
+				p.Runways = append(p.Runways, d)
+			}
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ A ChartEntry is an entry of the chart catalog of an edition: a classified chart of an airport
+ and its downloaded file.
+*/
+type ChartEntry struct {
+	Airport   string
+	Category  string
+	Title     string
+	Procedure string
+	Runways   []string
+	FileName  string
+	FilePath  string
+}
+
+/*
+	Get the chart catalog of the document, sorted by airport.
+	The charts of an airport are in the order of the chart list.
+*/
+func ChartCatalog(doc IAipDocument) []ChartEntry {
+	airports := doc.AirportsList()
+	sort.SliceStable(airports, func(i, j int) bool { return airports[i].Icao < airports[j].Icao })
+
+	var catalog []ChartEntry
+	for _, apt := range airports {
+		for _, p := range apt.PdfData {
+			if p.DataContentType != "Chart" {
+				continue
+			}
+			catalog = append(catalog, ChartEntry{
+				Airport:   apt.Icao,
+				Category:  p.Category,
+				Title:     p.Title,
+				Procedure: p.Procedure,
+				Runways:   p.Runways,
+				FileName:  p.FileName,
+				FilePath:  p.FilePath,
+			})
+		}
+	}
+	return catalog
+}
+
+/*
+	Export the chart catalog of the document as an indented JSON list.
+*/
+func ExportChartCatalog(doc IAipDocument, w io.Writer) error {
+	jsonData, err := json.MarshalIndent(ChartCatalog(doc), "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+/*
+	Write the chart catalog (charts.json) in the edition directory of the document.
+*/
+func WriteChartCatalog(doc IAipDocument) error {
+	return Export(doc, "charts", filepath.Join(doc.DirMainDownload(), "charts.json"))
+}
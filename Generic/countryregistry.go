@@ -0,0 +1,75 @@
+package generic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+ The country registry records the available CountryAis providers.
+ Each country package registers its provider (usually in an init function),
+ so the main program can run the countries listed in the configuration file
+ without knowing the country packages.
+ Names are not case sensitive.
+*/
+var (
+	countriesMu sync.RWMutex
+	countries   = make(map[string]CountryAis)
+)
+
+/*
+	Register a CountryAis provider under the indicated name.
+	It panics if the name is empty, if the provider is nil or if the name is already used,
+	as this can only be a programming error.
+*/
+func RegisterCountry(name string, ais CountryAis) {
+	countriesMu.Lock()
+	defer countriesMu.Unlock()
+
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		panic("RegisterCountry: empty country name")
+	}
+	if ais == nil {
+		panic("RegisterCountry: nil provider for " + name)
+	}
+	if _, dup := countries[key]; dup {
+		panic("RegisterCountry: called twice for " + name)
+	}
+	countries[key] = ais
+}
+
+/*
+	Get the CountryAis provider registered under the indicated name.
+*/
+func GetCountry(name string) (CountryAis, error) {
+	countriesMu.RLock()
+	defer countriesMu.RUnlock()
+
+	ais, ok := countries[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return nil, fmt.Errorf("no provider registered for country %s (available: %s)",
+			name, strings.Join(registeredCountries(), ", "))
+	}
+	return ais, nil
+}
+
+/*
+	Get the sorted list of the registered country names.
+*/
+func RegisteredCountries() []string {
+	countriesMu.RLock()
+	defer countriesMu.RUnlock()
+	return registeredCountries()
+}
+
+func registeredCountries() []string {
+	names := make([]string, 0, len(countries))
+	for n := range countries {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
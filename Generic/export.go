@@ -0,0 +1,69 @@
+package generic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+/*
+ An Exporter writes the content of an AIP document in a given format.
+ The exporters are recorded by format name and used by the export command.
+*/
+type Exporter func(doc IAipDocument, w io.Writer) error
+
+var exporters = map[string]Exporter{
+	"json":    ExportJson,
+	"geojson": ExportGeoJson,
+	"kml":     ExportKml,
+	"charts":  ExportChartCatalog,
+}
+
+/*
+	Get the sorted list of the available export formats.
+*/
+func ExportFormats() []string {
+	formats := make([]string, 0, len(exporters))
+	for f := range exporters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+/*
+	Export the AIP document in the indicated format and file.
+	The directory of the file is created if needed, the file is written atomically.
+*/
+func Export(doc IAipDocument, format string, path string) error {
+	exp, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("unknown export format %s", format)
+	}
+
+	return WriteFileAtomic(path, func(w io.Writer) error {
+		return exp(doc, w)
+	}, nil)
+}
+
+/*
+	Export the AIP document as an indented JSON report.
+	The concrete document is marshalled, so the country specific data are kept.
+*/
+func ExportJson(doc IAipDocument, w io.Writer) error {
+	jsonData, err := json.MarshalIndent(doc, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+/*
+	Write the report (info.json) in the edition directory of the document.
+*/
+func WriteReport(doc IAipDocument) error {
+	return Export(doc, "json", filepath.Join(doc.DirMainDownload(), "info.json"))
+}
@@ -0,0 +1,107 @@
+package generic
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+/*
+ A Failure records an error which occurred during a run.
+ The failure is attached to a country, and when relevant, to an airport and a file.
+*/
+type Failure struct {
+	Country string
+	Airport string
+	File    string
+	Err     error
+}
+
+func (f Failure) String() string {
+	s := f.Country
+	if f.Airport != "" {
+		s = s + " " + f.Airport
+	}
+	if f.File != "" {
+		s = s + " " + f.File
+	}
+	return fmt.Sprintf("%s: %s", s, f.Err)
+}
+
+/*
+ FailureReport aggregates the failures of a run.
+ It can be filled by several goroutines.
+*/
+type FailureReport struct {
+	mu       sync.Mutex
+	Failures []Failure
+}
+
+func (r *FailureReport) Add(f Failure) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, f)
+}
+
+// Merge adds the failures of another report.
+func (r *FailureReport) Merge(o *FailureReport) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	fs := append([]Failure(nil), o.Failures...)
+	o.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Failures = append(r.Failures, fs...)
+}
+
+func (r *FailureReport) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Failures)
+}
+
+/*
+	Determine if the number of failures is above the threshold.
+	A negative threshold accepts any number of failures.
+*/
+func (r *FailureReport) ExceedsThreshold(threshold int) bool {
+	return threshold >= 0 && r.Count() > threshold
+}
+
+func (r *FailureReport) Print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.Failures) == 0 {
+		fmt.Fprintln(w, "Failure report: no failure")
+		return
+	}
+	fmt.Fprintf(w, "Failure report: %d failure(s)\n", len(r.Failures))
+	for _, f := range r.Failures {
+		fmt.Fprintf(w, "  %s\n", f)
+	}
+}
+
+/*
+	Collect the failures recorded in the document, its airports and their PdfData.
+*/
+func CollectFailures(doc IAipDocument) *FailureReport {
+	report := &FailureReport{}
+	country := doc.Document().CountryCode
+	for _, err := range doc.Document().Errors {
+		report.Add(Failure{Country: country, Err: err})
+	}
+	for _, apt := range doc.AirportsList() {
+		for _, err := range apt.Errors {
+			report.Add(Failure{Country: country, Airport: apt.Icao, Err: err})
+		}
+		for _, pdf := range apt.PdfData {
+			if pdf.Err != nil {
+				report.Add(Failure{Country: country, Airport: apt.Icao, File: pdf.FileName, Err: pdf.Err})
+			}
+		}
+	}
+	return report
+}
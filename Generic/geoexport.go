@@ -0,0 +1,278 @@
+package generic
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+ A GeoFeature is a located item of the AIP document, as written by the geographic exports
+ (GeoJSON and KML). The properties are written as they are in the GeoJSON export
+ and as the description of the placemark in the KML export.
+ An item with a boundary (ex: an airspace) is a polygon, else it is a point.
+*/
+type GeoFeature struct {
+	Name       string
+	Kind       string
+	Position   GeoPosition
+	Boundary   []GeoPosition
+	Properties map[string]interface{}
+}
+
+/*
+	Get the located items of the document: the airports, the navaids, the waypoints, the airspaces
+	(including the aerodrome airspaces), the prohibited, restricted and danger areas and the obstacles.
+	The items without known position are not located, so they are not provided.
+*/
+func GeoFeatures(doc IAipDocument) []GeoFeature {
+	var features []GeoFeature
+	for _, apt := range doc.AirportsList() {
+		arp := apt.AdminData.ArpCoord
+		if arp.Latitude == 0 && arp.Longitude == 0 {
+			continue
+		}
+		features = append(features, GeoFeature{
+			Name:     apt.Icao,
+			Kind:     "airport",
+			Position: arp,
+			Properties: map[string]interface{}{
+				"icao":      apt.Icao,
+				"title":     apt.Title,
+				"type":      apt.AirportType,
+				"elevation": apt.AdminData.Elevation,
+				"com":       apt.Com,
+				"runways":   apt.Runways,
+			},
+		})
+	}
+	for _, n := range doc.Document().Navaids {
+		if n.Position.Latitude == 0 && n.Position.Longitude == 0 {
+			continue
+		}
+		features = append(features, GeoFeature{
+			Name:     n.Id,
+			Kind:     "navaid",
+			Position: n.Position,
+			Properties: map[string]interface{}{
+				"id":        n.Id,
+				"name":      n.Name,
+				"type":      n.NavaidType,
+				"frequency": n.Frequency,
+			},
+		})
+	}
+	for _, w := range doc.Document().Waypoints {
+		features = append(features, GeoFeature{
+			Name:     w.Id,
+			Kind:     "waypoint",
+			Position: w.Position,
+			Properties: map[string]interface{}{
+				"id":     w.Id,
+				"routes": w.Routes,
+			},
+		})
+	}
+	for _, a := range doc.Document().Airspaces {
+		if f, ok := airspaceFeature(a); ok {
+			features = append(features, f)
+		}
+	}
+	for _, apt := range doc.AirportsList() {
+		for _, a := range apt.Airspaces {
+			if f, ok := airspaceFeature(a); ok {
+				f.Properties["airport"] = apt.Icao
+				f.Properties["language"] = a.Language
+				f.Properties["transitionAltitude"] = a.TransitionAltitude.String()
+				features = append(features, f)
+			}
+		}
+	}
+	for _, r := range doc.Document().RestrictedAreas {
+		if len(r.Boundary) == 0 {
+			continue
+		}
+		features = append(features, GeoFeature{
+			Name:     r.Id,
+			Kind:     "restricted area",
+			Boundary: r.Boundary,
+			Properties: map[string]interface{}{
+				"id":            r.Id,
+				"type":          r.Type,
+				"name":          r.Name,
+				"upper":         r.Upper.String(),
+				"lower":         r.Lower.String(),
+				"activityHours": r.ActivityHours,
+				"remarks":       r.Remarks,
+			},
+		})
+	}
+	for _, o := range ObstaclesOf(doc) {
+		if o.Position.Latitude == 0 && o.Position.Longitude == 0 {
+			continue
+		}
+		features = append(features, GeoFeature{
+			Name:     o.Id,
+			Kind:     "obstacle",
+			Position: o.Position,
+			Properties: map[string]interface{}{
+				"id":        o.Id,
+				"type":      o.Type,
+				"elevation": o.Elevation,
+				"height":    o.Height,
+				"lighting":  o.Lighting,
+				"marking":   o.Marking,
+				"airport":   o.Airport,
+			},
+		})
+	}
+	return features
+}
+
+// airspaceFeature provides the feature of an airspace, if its boundary is known.
+func airspaceFeature(a Airspace) (GeoFeature, bool) {
+	if len(a.Boundary) == 0 {
+		return GeoFeature{}, false
+	}
+	return GeoFeature{
+		Name:     a.Name,
+		Kind:     "airspace",
+		Boundary: a.Boundary,
+		Properties: map[string]interface{}{
+			"name":        a.Name,
+			"type":        a.Type,
+			"class":       a.Class,
+			"upper":       a.Upper.String(),
+			"lower":       a.Lower.String(),
+			"unit":        a.Unit,
+			"callsign":    a.CallSign,
+			"frequencies": a.Frequencies,
+		},
+	}, true
+}
+
+type geoJsonGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+type geoJsonFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJsonGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJsonCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJsonFeature `json:"features"`
+}
+
+/*
+	Export the located items of the AIP document as a GeoJSON feature collection.
+	The kind of item is indicated by the "kind" property.
+*/
+func ExportGeoJson(doc IAipDocument, w io.Writer) error {
+	collection := geoJsonCollection{Type: "FeatureCollection", Features: []geoJsonFeature{}}
+	for _, f := range GeoFeatures(doc) {
+		props := map[string]interface{}{"kind": f.Kind}
+		for k, v := range f.Properties {
+			props[k] = v
+		}
+		geometry := geoJsonGeometry{
+			Type:        "Point",
+			Coordinates: []float32{f.Position.Longitude, f.Position.Latitude},
+		}
+		if len(f.Boundary) > 0 {
+			ring := make([][]float32, len(f.Boundary))
+			for i, p := range f.Boundary {
+				ring[i] = []float32{p.Longitude, p.Latitude}
+			}
+			geometry = geoJsonGeometry{Type: "Polygon", Coordinates: [][][]float32{ring}}
+		}
+		collection.Features = append(collection.Features, geoJsonFeature{
+			Type:       "Feature",
+			Geometry:   geometry,
+			Properties: props,
+		})
+	}
+
+	jsonData, err := json.MarshalIndent(collection, "", " ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(jsonData)
+	return err
+}
+
+type kmlPoint struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlPolygon struct {
+	Coordinates string `xml:"outerBoundaryIs>LinearRing>coordinates"`
+}
+
+type kmlPlacemark struct {
+	Name        string      `xml:"name"`
+	Description string      `xml:"description,omitempty"`
+	Point       *kmlPoint   `xml:"Point,omitempty"`
+	Polygon     *kmlPolygon `xml:"Polygon,omitempty"`
+}
+
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlDocument struct {
+	XMLName xml.Name    `xml:"kml"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Name    string      `xml:"Document>name"`
+	Folders []kmlFolder `xml:"Document>Folder"`
+}
+
+/*
+	Export the located items of the AIP document as a KML document.
+	The items are grouped in one folder per kind.
+*/
+func ExportKml(doc IAipDocument, w io.Writer) error {
+	d := doc.Document()
+	kml := kmlDocument{
+		Xmlns: "http://www.opengis.net/kml/2.2",
+		Name:  fmt.Sprintf("%s AIP %s", d.CountryCode, d.EffectiveDate.Format("2006-01-02")),
+	}
+
+	folders := make(map[string]int)
+	for _, f := range GeoFeatures(doc) {
+		i, ok := folders[f.Kind]
+		if !ok {
+			i = len(kml.Folders)
+			folders[f.Kind] = i
+			kml.Folders = append(kml.Folders, kmlFolder{Name: f.Kind})
+		}
+		desc, err := json.Marshal(f.Properties)
+		if err != nil {
+			return err
+		}
+		placemark := kmlPlacemark{Name: f.Name, Description: string(desc)}
+		if len(f.Boundary) > 0 {
+			coords := make([]string, len(f.Boundary))
+			for i, p := range f.Boundary {
+				coords[i] = fmt.Sprintf("%f,%f", p.Longitude, p.Latitude)
+			}
+			placemark.Polygon = &kmlPolygon{Coordinates: strings.Join(coords, " ")}
+		} else {
+			placemark.Point = &kmlPoint{Coordinates: fmt.Sprintf("%f,%f", f.Position.Longitude, f.Position.Latitude)}
+		}
+		kml.Folders[i].Placemarks = append(kml.Folders[i].Placemarks, placemark)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	return enc.Encode(kml)
+}
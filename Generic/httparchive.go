@@ -0,0 +1,228 @@
+package generic
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// HTTP modes of the configuration (ConfigurationDataStruct.HttpMode)
+const (
+	HttpModeLive   = ""
+	HttpModeRecord = "record"
+	HttpModeReplay = "replay"
+)
+
+// ErrNotRecorded is returned in replay mode when the archive has no response for a request.
+var ErrNotRecorded = errors.New("no recorded response")
+
+/*
+ An archived exchange: the request identification and the response.
+ The body is stored in a separate file, the request body is never stored (it may contain credentials).
+*/
+type archivedExchange struct {
+	Method     string
+	Url        string
+	Seq        int
+	StatusCode int
+	Status     string
+	Header     http.Header
+	BodyFile   string
+}
+
+// archiveFileRe matches the name of the description file of an exchange: <key>-<seq>.json
+var archiveFileRe = regexp.MustCompile(`^([0-9a-f]+)-[0-9]+\.json$`)
+
+// archiveKey identifies the exchanges of a request in the archive.
+func archiveKey(req *http.Request) string {
+	h := sha256.Sum256([]byte(req.Method + " " + req.URL.String()))
+	return hex.EncodeToString(h[:8])
+}
+
+/*
+ RecordingTransport writes each request/response pair in the archive directory
+ and provides the response to the caller unchanged.
+ The exchanges of a same request (method and url) are numbered in their order.
+*/
+type RecordingTransport struct {
+	Base http.RoundTripper
+	Dir  string
+	mu   sync.Mutex
+	seq  map[string]int
+}
+
+func NewRecordingTransport(base http.RoundTripper, dir string) *RecordingTransport {
+	return &RecordingTransport{Base: base, Dir: dir, seq: make(map[string]int)}
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	key := archiveKey(req)
+	t.mu.Lock()
+	seq := t.seq[key]
+	t.seq[key] = seq + 1
+	t.mu.Unlock()
+
+	name := fmt.Sprintf("%s-%04d", key, seq)
+	ex := archivedExchange{Method: req.Method, Url: req.URL.String(), Seq: seq,
+		StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header, BodyFile: name + ".body"}
+	if err := t.write(name, ex, body); err != nil {
+		return nil, fmt.Errorf("unable to record %s: %s", ex.Url, err)
+	}
+	return resp, nil
+}
+
+func (t *RecordingTransport) write(name string, ex archivedExchange, body []byte) error {
+	err := WriteFileAtomic(filepath.Join(t.Dir, ex.BodyFile), func(w io.Writer) error {
+		_, err := w.Write(body)
+		return err
+	}, nil)
+	if err != nil {
+		return err
+	}
+	meta, err := json.MarshalIndent(ex, "", " ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(t.Dir, name+".json"), func(w io.Writer) error {
+		_, err := w.Write(meta)
+		return err
+	}, nil)
+}
+
+/*
+ ReplayTransport serves the responses recorded by a RecordingTransport, without any network access.
+ The exchanges of a same request are served in their recorded order; once they are all served,
+ the last one is served again. A request without recording fails with ErrNotRecorded.
+*/
+type ReplayTransport struct {
+	Dir       string
+	mu        sync.Mutex
+	loaded    bool
+	exchanges map[string][]archivedExchange
+	next      map[string]int
+}
+
+func NewReplayTransport(dir string) *ReplayTransport {
+	return &ReplayTransport{Dir: dir}
+}
+
+func (t *ReplayTransport) load() error {
+	t.exchanges = make(map[string][]archivedExchange)
+	t.next = make(map[string]int)
+	files, err := filepath.Glob(filepath.Join(t.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		//only the files named <key>-<seq>.json are exchanges, the other files are ignored
+		m := archiveFileRe.FindStringSubmatch(filepath.Base(f))
+		if m == nil {
+			continue
+		}
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		var ex archivedExchange
+		if err := json.Unmarshal(data, &ex); err != nil {
+			return fmt.Errorf("%s: %s", f, err)
+		}
+		t.exchanges[m[1]] = append(t.exchanges[m[1]], ex)
+	}
+	for _, exs := range t.exchanges {
+		sort.Slice(exs, func(i, j int) bool { return exs[i].Seq < exs[j].Seq })
+	}
+	t.loaded = true
+	return nil
+}
+
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	t.mu.Lock()
+	if !t.loaded {
+		if err := t.load(); err != nil {
+			t.mu.Unlock()
+			return nil, fmt.Errorf("unable to load the HTTP archive %s: %s", t.Dir, err)
+		}
+	}
+	key := archiveKey(req)
+	exs := t.exchanges[key]
+	if len(exs) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNotRecorded)
+	}
+	i := t.next[key]
+	if i < len(exs)-1 {
+		t.next[key] = i + 1
+	}
+	ex := exs[i]
+	t.mu.Unlock()
+
+	body, err := ioutil.ReadFile(filepath.Join(t.Dir, ex.BodyFile))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        ex.Status,
+		StatusCode:    ex.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        ex.Header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+/*
+	Confirm the HTTP mode of the configuration and the archive directory.
+	In record mode, the directory is created.
+*/
+func (cds *ConfigurationDataStruct) CheckHttpMode() error {
+	mode, dir := cds.HttpMode, cds.HttpArchiveDir
+	switch mode {
+	case HttpModeLive:
+		return nil
+	case HttpModeRecord:
+		if dir == "" {
+			return errors.New("record mode requires an archive directory")
+		}
+		return os.MkdirAll(dir, os.ModePerm)
+	case HttpModeReplay:
+		if dir == "" {
+			return errors.New("replay mode requires an archive directory")
+		}
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown HTTP mode %s", mode)
+}
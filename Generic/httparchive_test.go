@@ -0,0 +1,56 @@
+package generic
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplayIgnoresOtherFiles(t *testing.T) {
+	archive, err := ioutil.TempDir("", "aiparchive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(archive)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, "%s %d", r.URL.Path, calls)
+	}))
+	defer server.Close()
+
+	record := &http.Client{Transport: NewRecordingTransport(http.DefaultTransport, archive)}
+	for _, path := range []string{"/a", "/a", "/b"} {
+		resp, err := record.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	//a file of the directory which is not an exchange
+	if err := ioutil.WriteFile(filepath.Join(archive, "foo.json"), []byte(`{"Url": "x"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	replay := &http.Client{Transport: NewReplayTransport(archive)}
+	for _, exp := range []struct{ path, body string }{{"/a", "/a 1"}, {"/b", "/b 3"}, {"/a", "/a 2"}, {"/a", "/a 2"}} {
+		resp, err := replay.Get(server.URL + exp.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != exp.body {
+			t.Errorf("%s: got %q, expected %q", exp.path, body, exp.body)
+		}
+	}
+	if _, err := replay.Get(server.URL + "/c"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+}
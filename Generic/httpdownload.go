@@ -0,0 +1,291 @@
+package generic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+ RetryPolicy defines how the failed HTTP requests are tried again.
+ The delay between two attempts grows exponentially from BaseDelayMs up to MaxDelayMs,
+ with a random jitter. A Retry-After header provided by the server takes precedence,
+ up to MaxDelayMs.
+ The zero values are replaced by the default values.
+*/
+type RetryPolicy struct {
+	MaxAttempts int `json:"maxAttempts"`
+	BaseDelayMs int `json:"baseDelayMs"`
+	MaxDelayMs  int `json:"maxDelayMs"`
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 4, BaseDelayMs: 1000, MaxDelayMs: 30000}
+
+func (rp RetryPolicy) withDefaults() RetryPolicy {
+	if rp.MaxAttempts <= 0 {
+		rp.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if rp.BaseDelayMs <= 0 {
+		rp.BaseDelayMs = DefaultRetryPolicy.BaseDelayMs
+	}
+	if rp.MaxDelayMs <= 0 {
+		rp.MaxDelayMs = DefaultRetryPolicy.MaxDelayMs
+	}
+	return rp
+}
+
+/*
+	Delay before the attempt following the indicated one (starting at 1).
+	Equal jitter: the delay is taken randomly between half and the whole exponential delay.
+*/
+func (rp RetryPolicy) backoff(attempt int) time.Duration {
+	d := time.Duration(rp.BaseDelayMs) * time.Millisecond
+	max := time.Duration(rp.MaxDelayMs) * time.Millisecond
+	for i := 1; i < attempt && d < max; i++ {
+		d = d * 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+/*
+ HttpStatusError is returned when the server answers with a status outside of the 2xx range.
+*/
+type HttpStatusError struct {
+	Url        string
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *HttpStatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Url, e.Status)
+}
+
+// Temporary indicates if the request may succeed later (timeout, throttling or server error).
+func (e *HttpStatusError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+/*
+	Decode the Retry-After header, expressed in seconds or as an HTTP date.
+	Return 0 if there is no valid header.
+*/
+func retryAfter(resp *http.Response) time.Duration {
+	h := resp.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(h); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// ErrNotModified is returned by a conditional request when the server answers 304 Not Modified.
+var ErrNotModified = errors.New("not modified")
+
+/*
+	Call the do function and call it again following the retry policy of the configuration
+	in case of transport error, temporary status (408, 429, 5xx) or any other error,
+	except a definitive status, ErrNotModified and ErrNotRecorded.
+*/
+func withRetry(do func() error) error {
+	rp := ConfData.Retry.withDefaults()
+	var lastErr error
+	for attempt := 1; attempt <= rp.MaxAttempts; attempt++ {
+		err := do()
+		if err == nil || err == ErrNotModified || errors.Is(err, ErrNotRecorded) {
+			return err
+		}
+		lastErr = err
+
+		delay := rp.backoff(attempt)
+		if se, ok := err.(*HttpStatusError); ok {
+			if !se.Temporary() {
+				return err
+			}
+			if se.RetryAfter > 0 {
+				delay = se.RetryAfter
+				//a worker shall not be blocked for hours by the server
+				if max := time.Duration(rp.MaxDelayMs) * time.Millisecond; delay > max {
+					log.Printf("%s - Retry-After %s limited to %s \n", err, delay, max)
+					delay = max
+				}
+			}
+		}
+		if attempt < rp.MaxAttempts {
+			log.Printf("%s - attempt %d/%d, retry in %s \n", err, attempt, rp.MaxAttempts, delay.Round(time.Millisecond))
+			time.Sleep(delay)
+		}
+	}
+	return lastErr
+}
+
+/*
+	Send a GET request and try it again following the retry policy of the configuration
+	in case of transport error or temporary status (408, 429, 5xx).
+	The response is returned only with a 2xx status, the caller shall close its body.
+*/
+func GetWithRetry(cl *http.Client, url string) (*http.Response, error) {
+	return GetConditional(cl, url, nil)
+}
+
+/*
+	Send a conditional GET request with the validators (ETag, Last-Modified) of the previous
+	download, and try it again following the retry policy.
+	ErrNotModified is returned when the server confirms the previous download is unchanged (304).
+	With nil validators, it is a simple GET request.
+	The response is returned only with a 2xx status, the caller shall close its body.
+*/
+func GetConditional(cl *http.Client, url string, validators *ManifestEntry) (*http.Response, error) {
+	var resp *http.Response
+	err := withRetry(func() error {
+		var err error
+		resp, err = getOnce(cl, url, validators)
+		return err
+	})
+	return resp, err
+}
+
+func getOnce(cl *http.Client, url string, validators *ManifestEntry) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if validators != nil {
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+	}
+
+	resp, err := cl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified && validators != nil {
+		resp.Body.Close()
+		return nil, ErrNotModified
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, &HttpStatusError{Url: url, StatusCode: resp.StatusCode, Status: resp.Status,
+			RetryAfter: retryAfter(resp)}
+	}
+	return resp, nil
+}
+
+/*
+	Download the url content in the file pathFile.
+	The download is tried again following the retry policy in case of transport error,
+	temporary status, truncated body or when the verify function rejects the file.
+	The file is written atomically (see WriteFileAtomic): when an error is returned, an
+	existing file is left unchanged, so a file on disk is always a complete and verified file.
+	When validators are provided (see Manifest.Validators), the request is conditional:
+	if the server answers 304, the local file is kept and the validators are returned.
+	verify and validators can be nil. It returns the manifest entry of the downloaded file.
+*/
+func DownloadFile(cl *http.Client, url string, pathFile string, verify func(pth string) error, validators *ManifestEntry) (ManifestEntry, error) {
+	var entry ManifestEntry
+	err := withRetry(func() error {
+		var err error
+		entry, err = downloadFileOnce(cl, url, pathFile, verify, validators)
+		return err
+	})
+	if err == ErrNotModified {
+		log.Printf("%s not modified, local copy %s kept \n", url, pathFile)
+		return *validators, nil
+	}
+	return entry, err
+}
+
+func downloadFileOnce(cl *http.Client, url string, pathFile string, verify func(pth string) error, validators *ManifestEntry) (ManifestEntry, error) {
+	resp, err := getOnce(cl, url, validators)
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	defer resp.Body.Close()
+
+	entry, err := SaveResponse(resp, url, pathFile, verify)
+	return entry, err
+}
+
+/*
+	Save the body of the response in the file pathFile, atomically.
+	The body is rejected if it is truncated or if the verify function (when not nil) rejects it.
+	It returns the manifest entry of the saved file.
+*/
+func SaveResponse(resp *http.Response, url string, pathFile string, verify func(pth string) error) (ManifestEntry, error) {
+	var n int64
+	h := sha256.New()
+	err := WriteFileAtomic(pathFile, func(w io.Writer) error {
+		var err error
+		n, err = io.Copy(io.MultiWriter(w, h), resp.Body)
+		if err != nil {
+			return err
+		}
+		if resp.ContentLength >= 0 && n != resp.ContentLength {
+			return fmt.Errorf("%s: truncated body, %d bytes received instead of %d", url, n, resp.ContentLength)
+		}
+		return nil
+	}, func(tmpPath string) error {
+		if verify == nil {
+			return nil
+		}
+		if err := verify(tmpPath); err != nil {
+			return fmt.Errorf("%s: %s", url, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return ManifestEntry{}, err
+	}
+	return ManifestEntry{
+		Url:          url,
+		Size:         n,
+		Sha256:       hex.EncodeToString(h.Sum(nil)),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		DownloadedAt: time.Now(),
+	}, nil
+}
+
+/*
+	Confirm that the file exists and starts with the PDF header (%PDF-).
+	An HTML error page saved with a .pdf extension is therefore rejected.
+*/
+func VerifyPdfFile(pth string) error {
+	f, err := os.Open(pth)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return fmt.Errorf("%s is not a PDF file: %s", pth, err)
+	}
+	if string(header) != "%PDF-" {
+		return fmt.Errorf("%s is not a PDF file", pth)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package generic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterIsLimited(t *testing.T) {
+	saved := ConfData
+	t.Cleanup(func() { ConfData = saved })
+	ConfData = ConfigurationDataStruct{Retry: RetryPolicy{MaxAttempts: 2, BaseDelayMs: 1, MaxDelayMs: 10}}
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	start := time.Now()
+	resp, err := GetWithRetry(server.Client(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	//the delay of a day is limited to MaxDelayMs
+	if calls != 2 || time.Since(start) > 5*time.Second {
+		t.Errorf("unexpected retry: %d calls in %s", calls, time.Since(start))
+	}
+}
@@ -0,0 +1,80 @@
+package generic
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+	Build the HTTP transport used by the country clients, in accordance with the configuration.
+	The requests are limited per host when RequestsPerSecond is set.
+	In record mode, the exchanges are written in the archive directory.
+	In replay mode, the responses are served from the archive directory, without network access.
+*/
+func NewTransport() http.RoundTripper {
+	if ConfData.HttpMode == HttpModeReplay {
+		return NewReplayTransport(ConfData.HttpArchiveDir)
+	}
+
+	var tr http.RoundTripper = http.DefaultTransport
+	if ConfData.RequestsPerSecond > 0 {
+		tr = NewRateLimitedTransport(tr, ConfData.RequestsPerSecond)
+	}
+	if ConfData.HttpMode == HttpModeRecord {
+		tr = NewRecordingTransport(tr, ConfData.HttpArchiveDir)
+	}
+	return tr
+}
+
+/*
+ RateLimitedTransport limits the number of requests sent per second to each host.
+ The requests are delayed, never rejected: each host has its next free slot, and a
+ request waits until its slot.
+*/
+type RateLimitedTransport struct {
+	Base     http.RoundTripper
+	interval time.Duration
+	mu       sync.Mutex
+	next     map[string]time.Time
+}
+
+func NewRateLimitedTransport(base http.RoundTripper, requestsPerSecond float64) *RateLimitedTransport {
+	return &RateLimitedTransport{
+		Base:     base,
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		next:     make(map[string]time.Time),
+	}
+}
+
+func (t *RateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.wait(req); err != nil {
+		return nil, err
+	}
+	return t.Base.RoundTrip(req)
+}
+
+// wait blocks until the slot of the request host, or until the request is cancelled.
+func (t *RateLimitedTransport) wait(req *http.Request) error {
+	t.mu.Lock()
+	now := time.Now()
+	slot := t.next[req.URL.Host]
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next[req.URL.Host] = slot.Add(t.interval)
+	t.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,160 @@
+package generic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Name of the manifest file, stored in each edition directory (see AipDocument.DirMainDownload).
+const ManifestFileName = "manifest.json"
+
+/*
+ ManifestEntry describes a downloaded file: its source, its content and the HTTP validators
+ provided by the server.
+*/
+type ManifestEntry struct {
+	Url          string    `json:"url"`
+	Size         int64     `json:"size"`
+	Sha256       string    `json:"sha256"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+/*
+ The Manifest records the files downloaded for an edition.
+ The entries are identified by the path of the file relative to the edition directory,
+ so the directory can be copied or restored elsewhere: a file is up to date when its
+ content matches its entry, whatever its modification time.
+ The manifest can be used by several goroutines.
+*/
+type Manifest struct {
+	mu      sync.Mutex
+	dir     string
+	Entries map[string]ManifestEntry `json:"entries"`
+}
+
+/*
+	Load the manifest of the edition directory.
+	A missing manifest provides an empty manifest.
+*/
+func LoadManifest(dir string) (*Manifest, error) {
+	m := &Manifest{dir: dir, Entries: make(map[string]ManifestEntry)}
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFileName))
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return &Manifest{dir: dir, Entries: make(map[string]ManifestEntry)}, err
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]ManifestEntry)
+	}
+	return m, nil
+}
+
+/*
+	Save the manifest in its edition directory.
+*/
+func (m *Manifest) Save() error {
+	m.mu.Lock()
+	data, err := json.MarshalIndent(m, "", " ")
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(m.dir, ManifestFileName), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}, nil)
+}
+
+// key provides the identification of the file in the manifest.
+func (m *Manifest) key(filePath string) string {
+	if rel, err := filepath.Rel(m.dir, filePath); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filePath)
+}
+
+func (m *Manifest) Record(filePath string, entry ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Entries[m.key(filePath)] = entry
+}
+
+func (m *Manifest) Lookup(filePath string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.Entries[m.key(filePath)]
+	return e, ok
+}
+
+func (m *Manifest) Remove(filePath string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.Entries, m.key(filePath))
+}
+
+/*
+	Determine if the file is up to date: the file has been downloaded from url
+	and its size and SHA-256 match the manifest entry.
+*/
+func (m *Manifest) IsUpToDate(filePath string, url string) bool {
+	e, ok := m.Lookup(filePath)
+	if !ok || e.Url != url {
+		return false
+	}
+	return e.Matches(filePath)
+}
+
+/*
+	Get the validators (ETag, Last-Modified) to send a conditional request for url.
+	They are provided only if the file has been downloaded from url, is unchanged and
+	the server provided at least one validator: a 304 answer then confirms the local file.
+	Return nil otherwise.
+*/
+func (m *Manifest) Validators(filePath string, url string) *ManifestEntry {
+	e, ok := m.Lookup(filePath)
+	if !ok || e.Url != url || (e.ETag == "" && e.LastModified == "") || !e.Matches(filePath) {
+		return nil
+	}
+	return &e
+}
+
+/*
+	Determine if the content of the file matches the entry (size and SHA-256).
+*/
+func (e ManifestEntry) Matches(filePath string) bool {
+	st, err := os.Stat(filePath)
+	if err != nil || st.Size() != e.Size {
+		return false
+	}
+	sum, err := FileSha256(filePath)
+	return err == nil && sum == e.Sha256
+}
+
+/*
+	Compute the SHA-256 of the file, in hexadecimal.
+*/
+func FileSha256(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,112 @@
+package generic
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const feetPerMeter = 3.28084
+
+/*
+ A Measure is a value published with its unit, such as an elevation (21 FT) or
+ a geoid undulation (37 M). The unit is kept as published, in upper case.
+*/
+type Measure struct {
+	Value float32
+	Unit  string
+}
+
+var measureRe = regexp.MustCompile(`(?i)(-?[0-9]+(?:\.[0-9]+)?)\s*(ft|km|nm|m)\b`)
+
+/*
+	Parse the first value followed by a unit in the text (ex: "21ft / 30.4°C" gives 21 FT).
+	The known units are FT, M, KM and NM, other words (ex: 5 min, 45 MHz) are not units.
+*/
+func ParseMeasure(t string) (Measure, error) {
+	m := measureRe.FindStringSubmatch(t)
+	if m == nil {
+		return Measure{}, errors.New(strings.TrimSpace(t) + " Not a valid format value{unit}")
+	}
+	v, err := strconv.ParseFloat(m[1], 32)
+	if err != nil {
+		return Measure{}, err
+	}
+	return Measure{Value: float32(v), Unit: strings.ToUpper(m[2])}, nil
+}
+
+/*
+	Get all the values followed by a unit in the text (ex: "198ft / 45m" gives 198 FT and 45 M).
+*/
+func ParseMeasures(t string) []Measure {
+	var measures []Measure
+	for _, m := range measureRe.FindAllStringSubmatch(t, -1) {
+		v, err := strconv.ParseFloat(m[1], 32)
+		if err != nil {
+			continue
+		}
+		measures = append(measures, Measure{Value: float32(v), Unit: strings.ToUpper(m[2])})
+	}
+	return measures
+}
+
+var frequencyRe = regexp.MustCompile(`(?i)([0-9]+(?:\.[0-9]+)?)\s*(MHz|kHz)`)
+
+/*
+	Get all the frequencies of the text (ex: "118.1MHz 126.0MHz" gives 118.1 MHZ and 126 MHZ).
+*/
+func ParseFrequencies(t string) []Measure {
+	var freqs []Measure
+	for _, m := range frequencyRe.FindAllStringSubmatch(t, -1) {
+		v, err := strconv.ParseFloat(m[1], 32)
+		if err != nil {
+			continue
+		}
+		freqs = append(freqs, Measure{Value: float32(v), Unit: strings.ToUpper(m[2])})
+	}
+	return freqs
+}
+
+/*
+	Get the measure in feet. Only the feet and the meters are converted,
+	the second value is false for any other unit.
+*/
+func (m Measure) InFeet() (float32, bool) {
+	switch m.Unit {
+	case "FT":
+		return m.Value, true
+	case "M":
+		return m.Value * feetPerMeter, true
+	}
+	return 0, false
+}
+
+var magVarRe = regexp.MustCompile(`([0-9]+(?:\.[0-9]+)?)\s*°?\s*(?:([0-9]+(?:\.[0-9]+)?)\s*')?\s*([EW])?`)
+
+/*
+	Convert a magnetic variation (ex: 7°W, 0.1°E, 7°30'W) in degrees.
+	The east variations are positive, the west variations are negative.
+	A value without direction (ex: 0°) is considered as positive.
+*/
+func ConvertMagVariationToFloat(t string) (float32, error) {
+	m := magVarRe.FindStringSubmatch(t)
+	if m == nil {
+		return 0.0, errors.New(strings.TrimSpace(t) + " Not a valid format DD°MM'{E|W}")
+	}
+	deg, err := strconv.ParseFloat(m[1], 32)
+	if err != nil {
+		return 0.0, err
+	}
+	if m[2] != "" {
+		min, err := strconv.ParseFloat(m[2], 32)
+		if err != nil {
+			return 0.0, err
+		}
+		deg += min / 60
+	}
+	if m[3] == "W" {
+		return float32(-deg), nil
+	}
+	return float32(deg), nil
+}
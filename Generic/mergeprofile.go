@@ -0,0 +1,118 @@
+package generic
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+/*
+ A MergeProfile defines a merged file produced for each airport (ex: a departure pack).
+ The Include list is ordered: the files are merged selector after selector, and in the order
+ of the airport within a selector. A selector is one of:
+  - a chart category (ex: SID, see ChartCategory),
+  - a type of content (Text for the text of the airport part, Chart for any chart),
+  - a pattern of file name (ex: *-2.24.1-*.pdf, see filepath.Match).
+ A file selected by several selectors is merged once, at its first place.
+ The Output is the name of the merged file, where {ICAO} is replaced by the ICAO code
+ of the airport and {NAME} by the name of the profile (ex: {ICAO}_departure.pdf).
+ It shall contain {ICAO}, so each airport has its own merged file.
+*/
+type MergeProfile struct {
+	Name    string   `json:"name"`
+	Include []string `json:"include"`
+	Output  string   `json:"output"`
+}
+
+/*
+	The profiles used when none is configured: the full document and the charts.
+*/
+var DefaultMergeProfiles = []MergeProfile{
+	{Name: "full", Include: []string{"*"}, Output: "{ICAO}_full.pdf"},
+	{Name: "chart", Include: []string{"Chart"}, Output: "{ICAO}_chart.pdf"},
+}
+
+/*
+	Get the merge profiles of the configuration, the default ones if none is configured.
+*/
+func (cds *ConfigurationDataStruct) MergeProfileList() []MergeProfile {
+	if len(cds.MergeProfiles) > 0 {
+		return cds.MergeProfiles
+	}
+	return DefaultMergeProfiles
+}
+
+/*
+	Check the merge profiles of the configuration: each profile shall have a unique name,
+	at least one selector, valid patterns and an output file name distinct from the other ones
+	(the names are compared without case, as on a case insensitive file system).
+*/
+func (cds *ConfigurationDataStruct) CheckMergeProfiles() error {
+	names := make(map[string]bool)
+	outputs := make(map[string]string)
+	for i, p := range cds.MergeProfiles {
+		if p.Name == "" {
+			return fmt.Errorf("merge profile %d has no name", i+1)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("merge profile %s defined several times", p.Name)
+		}
+		names[p.Name] = true
+		if len(p.Include) == 0 {
+			return fmt.Errorf("merge profile %s has no selector", p.Name)
+		}
+		for _, s := range p.Include {
+			if _, err := filepath.Match(s, ""); err != nil {
+				return fmt.Errorf("merge profile %s selector %s: %s", p.Name, s, err)
+			}
+		}
+		if p.Output == "" || p.Output != filepath.Base(p.Output) {
+			return fmt.Errorf("merge profile %s: invalid output %q", p.Name, p.Output)
+		}
+		if !strings.Contains(p.Output, "{ICAO}") {
+			return fmt.Errorf("merge profile %s: output %q does not contain {ICAO}", p.Name, p.Output)
+		}
+		out := strings.ToLower(p.OutputName("ICAO"))
+		if other, ok := outputs[out]; ok {
+			return fmt.Errorf("merge profiles %s and %s have the same output %q", other, p.Name, p.Output)
+		}
+		outputs[out] = p.Name
+	}
+	return nil
+}
+
+/*
+	Get the name of the merged file of the airport.
+*/
+func (p MergeProfile) OutputName(icao string) string {
+	return strings.NewReplacer("{ICAO}", icao, "{NAME}", p.Name).Replace(p.Output)
+}
+
+/*
+	Get the files of the airport selected by the profile, in the order of the profile.
+*/
+func (p MergeProfile) Select(pdfs []PdfData) []PdfData {
+	var selected []PdfData
+	used := make([]bool, len(pdfs))
+	for _, s := range p.Include {
+		for i, pdf := range pdfs {
+			if !used[i] && pdf.IsSelectedBy(s) {
+				used[i] = true
+				selected = append(selected, pdf)
+			}
+		}
+	}
+	return selected
+}
+
+/*
+	Check if the file is selected by the selector of a merge profile:
+	its category, its type of content or a pattern matching its file name.
+*/
+func (p *PdfData) IsSelectedBy(selector string) bool {
+	if strings.EqualFold(selector, p.DataContentType) || (p.Category != "" && strings.EqualFold(selector, p.Category)) {
+		return true
+	}
+	ok, _ := filepath.Match(selector, p.FileName)
+	return ok
+}
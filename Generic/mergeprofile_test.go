@@ -0,0 +1,42 @@
+package generic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckMergeProfiles(t *testing.T) {
+	for _, profiles := range [][]MergeProfile{
+		{{Name: "", Include: []string{"Text"}, Output: "{ICAO}.pdf"}},
+		{{Name: "a", Include: []string{"Text"}, Output: "{ICAO}.pdf"}, {Name: "a", Include: []string{"SID"}, Output: "{ICAO}_a.pdf"}},
+		{{Name: "a", Output: "{ICAO}.pdf"}},
+		{{Name: "a", Include: []string{"[SID"}, Output: "{ICAO}.pdf"}},
+		{{Name: "a", Include: []string{"SID"}, Output: "../{ICAO}.pdf"}},
+		{{Name: "a", Include: []string{"SID"}, Output: "departure.pdf"}},
+		{{Name: "a", Include: []string{"SID"}, Output: "{ICAO}_pack.pdf"}, {Name: "b", Include: []string{"STAR"}, Output: "{ICAO}_pack.pdf"}},
+		{{Name: "pack", Include: []string{"SID"}, Output: "{ICAO}_{NAME}.pdf"}, {Name: "b", Include: []string{"STAR"}, Output: "{ICAO}_PACK.pdf"}},
+	} {
+		conf := ConfigurationDataStruct{MergeProfiles: profiles}
+		if err := conf.CheckMergeProfiles(); err == nil {
+			t.Errorf("expected an error for %+v", profiles)
+		}
+	}
+
+	pdfs := []PdfData{
+		{DataContentType: "Text", FileName: "text.pdf"},
+		{DataContentType: "Chart", Category: ChartIAC, FileName: "iac.pdf"},
+		{DataContentType: "Chart", Category: ChartSTAR, FileName: "star.pdf"},
+		{DataContentType: "Chart", Category: ChartADC, FileName: "adc.pdf"},
+	}
+	arrival := MergeProfile{Name: "arrival", Include: []string{"star", "IAC", "*.pdf"}, Output: "{ICAO}_{NAME}.pdf"}
+	var names []string
+	for _, p := range arrival.Select(pdfs) {
+		names = append(names, p.FileName)
+	}
+	if strings.Join(names, " ") != "star.pdf iac.pdf text.pdf adc.pdf" {
+		t.Errorf("unexpected selection %v", names)
+	}
+	if arrival.OutputName("RJTT") != "RJTT_arrival.pdf" {
+		t.Errorf("unexpected output %s", arrival.OutputName("RJTT"))
+	}
+}
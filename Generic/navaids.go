@@ -84,7 +84,7 @@ func (n *Navaid) setColumn4(html *goquery.Selection) {
 			n.Position.Longitude = long
 		}
 	} else {
-		log.Printf("%s Conversion problem %s \n", n.Name)
+		log.Printf("%s Conversion problem %v \n", n.Name, data)
 	}
 }
 
@@ -0,0 +1,50 @@
+package generic
+
+import "sort"
+
+/*
+ An Obstacle is an obstacle to air navigation: an aerodrome obstacle (AD 2.10)
+ or an en-route obstacle (ENR 5.4).
+ The elevation is the elevation of the top of the obstacle, the height is above the ground.
+ The altitude of the position is the elevation in feet.
+ The Airport is the ICAO code of the aerodrome publishing the obstacle, empty for an en-route obstacle.
+*/
+type Obstacle struct {
+	Id        string
+	Type      string
+	Position  GeoPosition
+	Elevation Measure
+	Height    Measure
+	Lighting  string
+	Marking   string
+	Airport   string
+	Remarks   string
+}
+
+/*
+	Get all the obstacles of the document: the en-route obstacles, then the obstacles of each airport.
+*/
+func ObstaclesOf(doc IAipDocument) []Obstacle {
+	obstacles := append([]Obstacle{}, doc.Document().Obstacles...)
+	for _, apt := range doc.AirportsList() {
+		obstacles = append(obstacles, apt.Obstacles...)
+	}
+	return obstacles
+}
+
+/*
+	Get the obstacles within the radius (in nautical miles) around the centre,
+	sorted from the nearest to the farthest.
+*/
+func ObstaclesWithin(obstacles []Obstacle, centre GeoPosition, radius float32) []Obstacle {
+	var near []Obstacle
+	for _, o := range obstacles {
+		if centre.DistanceTo(o.Position) <= radius {
+			near = append(near, o)
+		}
+	}
+	sort.SliceStable(near, func(i, j int) bool {
+		return centre.DistanceTo(near[i].Position) < centre.DistanceTo(near[j].Position)
+	})
+	return near
+}
@@ -0,0 +1,37 @@
+package generic
+
+/*
+ The direction restriction of a route segment.
+ Forward is the published order of the points (From to To).
+*/
+type RouteDirection int
+
+const (
+	BothDirections RouteDirection = iota
+	ForwardOnly
+	BackwardOnly
+)
+
+/*
+ A Route is an ATS route (ENR 3) with its ordered segments.
+ The points of the segments are the identifiers of navaids (ENR 4.1) or waypoints (ENR 4.4).
+*/
+type Route struct {
+	Designator string
+	Segments   []RouteSegment
+}
+
+/*
+ A RouteSegment joins two consecutive significant points of a route.
+ The track is the published track from the From point, the distance is in nautical miles.
+*/
+type RouteSegment struct {
+	From      string
+	To        string
+	Track     float32
+	Distance  float32
+	Upper     VerticalLimit
+	Lower     VerticalLimit
+	Direction RouteDirection
+	Remarks   string
+}
@@ -0,0 +1,117 @@
+package generic
+
+import (
+	"container/heap"
+	"fmt"
+)
+
+/*
+ A RouteLeg is a step of a path over the route network.
+ The distance is the one of the edge (see NewRouteGraph), in nautical miles.
+*/
+type RouteLeg struct {
+	Route    string
+	From     RouteNode
+	To       RouteNode
+	Distance float32
+}
+
+/*
+	Check if the edge can be flown at a level of the band [minLevel, maxLevel].
+	An unknown limit does not restrict the edge.
+*/
+func (e RouteEdge) IsInLevelBand(minLevel int, maxLevel int) bool {
+	if e.Lower.Unit != "" && e.Lower.FlightLevel() > maxLevel {
+		return false
+	}
+	if e.Upper.Unit != "" && e.Upper.FlightLevel() < minLevel {
+		return false
+	}
+	return true
+}
+
+type pathItem struct {
+	id       string
+	distance float32
+	estimate float32
+}
+
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int            { return len(q) }
+func (q pathQueue) Less(i, j int) bool  { return q[i].estimate < q[j].estimate }
+func (q pathQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x interface{}) { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+/*
+	Get the shortest path over the route network between two points.
+	Only the edges which can be flown in the flight level band [minLevel, maxLevel] are used,
+	the direction restrictions are given by the edges of the graph.
+	The lengths of the legs are the distances of the edges (the published ones when known),
+	the great circle distance to the destination guides the search (A* algorithm).
+*/
+func (g *RouteGraph) ShortestPath(from string, to string, minLevel int, maxLevel int) ([]RouteLeg, error) {
+	if minLevel > maxLevel {
+		return nil, fmt.Errorf("invalid level band FL%03d - FL%03d", minLevel, maxLevel)
+	}
+	start, ok := g.Nodes[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown point %s", from)
+	}
+	dest, ok := g.Nodes[to]
+	if !ok {
+		return nil, fmt.Errorf("unknown point %s", to)
+	}
+
+	distances := map[string]float32{from: 0}
+	previous := make(map[string]RouteEdge)
+	done := make(map[string]bool)
+	queue := &pathQueue{{id: from, estimate: start.Position.DistanceTo(dest.Position)}}
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(pathItem)
+		if done[item.id] {
+			continue
+		}
+		done[item.id] = true
+		if item.id == to {
+			break
+		}
+
+		for _, e := range g.Edges[item.id] {
+			next, ok := g.Nodes[e.To]
+			if !ok || done[e.To] || !e.IsInLevelBand(minLevel, maxLevel) {
+				continue
+			}
+			d := item.distance + e.Distance
+			if known, ok := distances[e.To]; ok && known <= d {
+				continue
+			}
+			distances[e.To] = d
+			previous[e.To] = e
+			heap.Push(queue, pathItem{id: e.To, distance: d, estimate: d + next.Position.DistanceTo(dest.Position)})
+		}
+	}
+
+	if !done[to] {
+		return nil, fmt.Errorf("no route from %s to %s between FL%03d and FL%03d", from, to, minLevel, maxLevel)
+	}
+
+	var legs []RouteLeg
+	for id := to; id != from; {
+		e := previous[id]
+		legs = append([]RouteLeg{{
+			Route:    e.Route,
+			From:     g.Nodes[e.From],
+			To:       g.Nodes[e.To],
+			Distance: e.Distance,
+		}}, legs...)
+		id = e.From
+	}
+	return legs, nil
+}
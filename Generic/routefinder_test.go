@@ -0,0 +1,96 @@
+package generic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRouteGraph(t *testing.T) {
+	navaids := []Navaid{{Id: "HWE", Position: GeoPosition{Latitude: 41.77, Longitude: 140.82}}}
+	waypoints := []Waypoint{
+		{Id: "ABSAK", Position: GeoPosition{Latitude: 42.18, Longitude: 141.11}},
+		{Id: "ADDUM", Position: GeoPosition{Latitude: 34.65, Longitude: 139.90}},
+	}
+	routes := []Route{
+		{Designator: "A1", Segments: []RouteSegment{{From: "ABSAK", To: "HWE"}, {From: "HWE", To: "ZZZZZ"}}},
+		{Designator: "Y11", Segments: []RouteSegment{{From: "ADDUM", To: "ABSAK", Distance: 453.2, Direction: ForwardOnly}}},
+	}
+
+	g, problems := NewRouteGraph(routes, navaids, waypoints)
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "ZZZZZ") {
+		t.Errorf("unexpected problems %v", problems)
+	}
+	//A1 in both directions, the distance is computed
+	if e := g.Neighbours("HWE"); len(e) != 1 || e[0].To != "ABSAK" || e[0].Distance < 25 || e[0].Distance > 30 {
+		t.Errorf("unexpected HWE edges %+v", e)
+	}
+	//Y11 is one way, from ADDUM to ABSAK
+	if e := g.Neighbours("ABSAK"); len(e) != 1 || e[0].Route != "A1" {
+		t.Errorf("unexpected ABSAK edges %+v", e)
+	}
+	if e := g.Neighbours("ADDUM"); len(e) != 1 || e[0].Route != "Y11" || e[0].Distance != 453.2 {
+		t.Errorf("unexpected ADDUM edges %+v", e)
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	navaids := []Navaid{{Id: "HWE", Position: GeoPosition{Latitude: 41.77, Longitude: 140.82}}}
+	waypoints := []Waypoint{
+		{Id: "ABSAK", Position: GeoPosition{Latitude: 42.18, Longitude: 141.11}},
+		{Id: "ADDUM", Position: GeoPosition{Latitude: 34.65, Longitude: 139.90}},
+	}
+	fl600 := VerticalLimit{Value: 600, Unit: "FL", Reference: "STD"}
+	routes := []Route{
+		{Designator: "A1", Segments: []RouteSegment{
+			{From: "ABSAK", To: "HWE", Distance: 25.3, Upper: fl600, Lower: VerticalLimit{Value: 7000, Unit: "FT", Reference: "AMSL"}},
+			{From: "HWE", To: "ADDUM", Distance: 427.5, Upper: fl600, Lower: VerticalLimit{Value: 200, Unit: "FL", Reference: "STD"}},
+		}},
+		{Designator: "Y11", Segments: []RouteSegment{
+			{From: "ADDUM", To: "ABSAK", Distance: 453.2, Direction: ForwardOnly,
+				Upper: VerticalLimit{Value: 410, Unit: "FL", Reference: "STD"}, Lower: VerticalLimit{Value: 250, Unit: "FL", Reference: "STD"}},
+		}},
+	}
+	g, problems := NewRouteGraph(routes, navaids, waypoints)
+	if len(problems) != 0 {
+		t.Fatal(problems)
+	}
+
+	path := func(legs []RouteLeg) string {
+		var p []string
+		for _, l := range legs {
+			p = append(p, l.From.Id+" "+l.Route)
+		}
+		return strings.Join(p, " ")
+	}
+
+	//the direct one way route Y11 is the shortest
+	legs, err := g.ShortestPath("ADDUM", "ABSAK", 250, 410)
+	if err != nil || path(legs) != "ADDUM Y11" {
+		t.Errorf("unexpected path %s (%v)", path(legs), err)
+	}
+	//Y11 cannot be flown in the opposite direction
+	legs, err = g.ShortestPath("ABSAK", "ADDUM", 250, 410)
+	if err != nil || path(legs) != "ABSAK A1 HWE A1" {
+		t.Errorf("unexpected path %s (%v)", path(legs), err)
+	}
+	var total float32
+	for _, l := range legs {
+		total += l.Distance
+	}
+	//the published distances 25.3 and 427.5
+	if total < 452.7 || total > 452.9 {
+		t.Errorf("unexpected total distance %f", total)
+	}
+	//Y11 is below the band
+	legs, err = g.ShortestPath("ADDUM", "ABSAK", 420, 500)
+	if err != nil || path(legs) != "ADDUM A1 HWE A1" {
+		t.Errorf("unexpected path %s (%v)", path(legs), err)
+	}
+	//all the routes are above the band
+	if _, err := g.ShortestPath("ADDUM", "ABSAK", 100, 150); err == nil {
+		t.Error("expected no route between FL100 and FL150")
+	}
+	if _, err := g.ShortestPath("ADDUM", "NOWHERE", 0, 999); err == nil {
+		t.Error("expected an error for an unknown point")
+	}
+}
@@ -0,0 +1,106 @@
+package generic
+
+import (
+	"fmt"
+	"sort"
+)
+
+/*
+ A RouteNode is a significant point of the route network, located by a navaid or a waypoint.
+*/
+type RouteNode struct {
+	Id       string
+	Kind     string
+	Position GeoPosition
+}
+
+/*
+ A RouteEdge is a segment of a route which can be flown from the From point to the To point.
+ A segment without direction restriction gives two edges, one in each direction.
+ The distance is in nautical miles.
+*/
+type RouteEdge struct {
+	Route    string
+	From     string
+	To       string
+	Distance float32
+	Upper    VerticalLimit
+	Lower    VerticalLimit
+}
+
+/*
+ The RouteGraph is the directed graph of the ATS route network.
+ The nodes are indexed by identifier, the edges by identifier of their From node.
+*/
+type RouteGraph struct {
+	Nodes map[string]RouteNode
+	Edges map[string][]RouteEdge
+}
+
+/*
+	Build the route graph of the routes. The points of the segments are resolved against
+	the navaids and the waypoints. A segment with an unknown point is not in the graph,
+	it is reported in the returned errors.
+	The distance of a segment is the published one, or the great circle distance when not published.
+*/
+func NewRouteGraph(routes []Route, navaids []Navaid, waypoints []Waypoint) (*RouteGraph, []error) {
+	g := &RouteGraph{Nodes: make(map[string]RouteNode), Edges: make(map[string][]RouteEdge)}
+	for _, n := range navaids {
+		if _, ok := g.Nodes[n.Id]; !ok {
+			g.Nodes[n.Id] = RouteNode{Id: n.Id, Kind: "navaid", Position: n.Position}
+		}
+	}
+	for _, w := range waypoints {
+		if _, ok := g.Nodes[w.Id]; !ok {
+			g.Nodes[w.Id] = RouteNode{Id: w.Id, Kind: "waypoint", Position: w.Position}
+		}
+	}
+
+	var problems []error
+	for _, r := range routes {
+		for _, s := range r.Segments {
+			from, fromOk := g.Nodes[s.From]
+			to, toOk := g.Nodes[s.To]
+			if !fromOk || !toOk {
+				problems = append(problems, fmt.Errorf("route %s segment %s - %s: unknown point", r.Designator, s.From, s.To))
+				continue
+			}
+			dist := s.Distance
+			if dist == 0 {
+				dist = from.Position.DistanceTo(to.Position)
+			}
+			edge := RouteEdge{Route: r.Designator, Distance: dist, Upper: s.Upper, Lower: s.Lower}
+			if s.Direction != BackwardOnly {
+				edge.From, edge.To = s.From, s.To
+				g.Edges[s.From] = append(g.Edges[s.From], edge)
+			}
+			if s.Direction != ForwardOnly {
+				edge.From, edge.To = s.To, s.From
+				g.Edges[s.To] = append(g.Edges[s.To], edge)
+			}
+		}
+	}
+	return g, problems
+}
+
+/*
+	Build the route graph of the routes, navaids and waypoints of the document.
+*/
+func RouteGraphOf(doc IAipDocument) (*RouteGraph, []error) {
+	d := doc.Document()
+	return NewRouteGraph(d.Routes, d.Navaids, d.Waypoints)
+}
+
+/*
+	Get the edges leaving the indicated point, sorted by route and destination.
+*/
+func (g *RouteGraph) Neighbours(id string) []RouteEdge {
+	edges := append([]RouteEdge{}, g.Edges[id]...)
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].Route != edges[j].Route {
+			return edges[i].Route < edges[j].Route
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
@@ -0,0 +1,50 @@
+package generic
+
+import (
+	"regexp"
+	"strings"
+)
+
+/*
+ A Runway describes one direction of a runway of the airport (AD 2.12 and AD 2.13).
+ The dimensions and the declared distances are kept with their unit.
+ The altitude of the threshold position is the threshold elevation in feet.
+*/
+type Runway struct {
+	Designator         string
+	TrueBearing        float32
+	Length             Measure
+	Width              Measure
+	Surface            string
+	Pcn                string
+	Threshold          GeoPosition
+	ThresholdElevation Measure
+	Tora               Measure
+	Toda               Measure
+	Asda               Measure
+	Lda                Measure
+}
+
+var runwayDesignatorRe = regexp.MustCompile(`^(0[1-9]|[1-2][0-9]|3[0-6])[LRC]?$`)
+
+/*
+	Normalize a runway designator (ex: "RWY 16R" gives "16R").
+	The second value is false if the text is not a runway designator.
+*/
+func RunwayDesignator(t string) (string, bool) {
+	d := strings.Join(strings.Fields(strings.ToUpper(t)), "")
+	d = strings.TrimPrefix(d, "RWY")
+	return d, runwayDesignatorRe.MatchString(d)
+}
+
+/*
+	Get the runway of the airport with the indicated designator, nil if unknown.
+*/
+func (a *Airport) Runway(designator string) *Runway {
+	for i := range a.Runways {
+		if a.Runways[i].Designator == designator {
+			return &a.Runways[i]
+		}
+	}
+	return nil
+}
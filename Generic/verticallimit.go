@@ -0,0 +1,105 @@
+package generic
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+ A VerticalLimit is an upper or lower limit of a route or an airspace.
+ The value is a flight level (Unit FL) or an altitude/height in feet (Unit FT).
+ The Reference is STD for the flight levels, AMSL or AGL for the altitudes and heights,
+ SFC for the surface (or ground) and UNL for an unlimited upper limit.
+*/
+type VerticalLimit struct {
+	Value     int
+	Unit      string
+	Reference string
+}
+
+var (
+	flightLevelRe = regexp.MustCompile(`(?i)FL\s*([0-9]{2,3})`)
+	altitudeRe    = regexp.MustCompile(`(?i)([0-9][0-9,]*)\s*(FT|M)\b\s*(AMSL|ALT|MSL|AGL|HGT|GND)?`)
+)
+
+/*
+	Parse a vertical limit (ex: FL245, 7000 FT ALT, 1500ft AGL, SFC, GND, UNL).
+	The altitudes in meters are converted in feet.
+*/
+func ParseVerticalLimit(t string) (VerticalLimit, error) {
+	up := strings.ToUpper(strings.TrimSpace(t))
+	if m := flightLevelRe.FindStringSubmatch(up); m != nil {
+		fl, _ := strconv.Atoi(m[1])
+		return VerticalLimit{Value: fl, Unit: "FL", Reference: "STD"}, nil
+	}
+	if m := altitudeRe.FindStringSubmatch(up); m != nil {
+		v, err := strconv.Atoi(strings.Replace(m[1], ",", "", -1))
+		if err != nil {
+			return VerticalLimit{}, err
+		}
+		if m[2] == "M" {
+			v = int(float32(v)*feetPerMeter + 0.5)
+		}
+		ref := "AMSL"
+		if m[3] == "AGL" || m[3] == "HGT" || m[3] == "GND" {
+			ref = "AGL"
+		}
+		return VerticalLimit{Value: v, Unit: "FT", Reference: ref}, nil
+	}
+	switch {
+	case strings.Contains(up, "UNL"):
+		return VerticalLimit{Unit: "FL", Reference: "UNL"}, nil
+	case strings.Contains(up, "SFC") || strings.Contains(up, "GND"):
+		return VerticalLimit{Unit: "FT", Reference: "SFC"}, nil
+	}
+	return VerticalLimit{}, errors.New(strings.TrimSpace(t) + " Not a valid vertical limit")
+}
+
+var verticalLimitRe = regexp.MustCompile(`(?i)FL\s*[0-9]{2,3}|[0-9][0-9,]*\s*(?:FT|M)\b(?:\s*(?:AMSL|ALT|MSL|AGL|HGT|GND))?|UNL|SFC|GND`)
+
+/*
+	Parse all the vertical limits of the text, in their order (ex: "FL600 / 7000 FT ALT" gives FL600 and 7000 FT AMSL).
+*/
+func ParseVerticalLimits(t string) []VerticalLimit {
+	var limits []VerticalLimit
+	for _, l := range verticalLimitRe.FindAllString(t, -1) {
+		if v, err := ParseVerticalLimit(l); err == nil {
+			limits = append(limits, v)
+		}
+	}
+	return limits
+}
+
+/*
+	Get the limit as an approximate flight level (the altitudes are divided by 100 ft).
+	The surface is the level 0, an unlimited limit is the level 999.
+*/
+func (v VerticalLimit) FlightLevel() int {
+	switch {
+	case v.Reference == "UNL":
+		return 999
+	case v.Reference == "SFC":
+		return 0
+	case v.Unit == "FL":
+		return v.Value
+	}
+	return v.Value / 100
+}
+
+/*
+	Get the text of the limit, as published (ex: FL245, 7000 FT AMSL, SFC, UNL).
+	An unknown limit gives an empty text.
+*/
+func (v VerticalLimit) String() string {
+	switch {
+	case v.Unit == "":
+		return ""
+	case v.Reference == "UNL" || v.Reference == "SFC":
+		return v.Reference
+	case v.Unit == "FL":
+		return "FL" + strconv.Itoa(v.Value)
+	}
+	return strconv.Itoa(v.Value) + " FT " + v.Reference
+}
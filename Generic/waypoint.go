@@ -0,0 +1,12 @@
+package generic
+
+/*
+ A Waypoint is a designated significant point of the en-route structure (ENR 4.4).
+ The Routes are the designators of the ATS routes or other routes using the point.
+*/
+type Waypoint struct {
+	Id       string
+	Position GeoPosition
+	Routes   []string
+	Remarks  string
+}
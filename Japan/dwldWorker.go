@@ -6,9 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"sync"
-	"time"
 	"github.com/NagoDede/aipdownloader/generic"
 
 )
@@ -23,45 +21,39 @@ func worker(id int, url string, client *http.Client, jobs chan *generic.PdfData)
 	for j := range jobs {
 
 		mainUrl := url + j.Link
-		status := downloadPDF(mainUrl, j.FilePath, client)
-		j.DownloadStatus = status
+		validators := j.ParentAirport.AipDocument.Manifest().Validators(j.FilePath, mainUrl)
+		entry, err := downloadPDF(mainUrl, j.FilePath, client, validators)
+		if err == nil {
+			j.ParentAirport.AipDocument.Manifest().Record(j.FilePath, entry)
+		}
+		j.Err = err
+		j.DownloadStatus = err == nil
+		if err != nil {
+			log.Printf("%s unable to download %s: %s \n", j.ParentAirport.Icao, mainUrl, err)
+		} else {
+			nb := j.ParentAirport.AddDownloaded()
+			fmt.Printf("%s downloaded %d / %d \n", j.ParentAirport.Icao, nb, len(j.ParentAirport.PdfData))
+		}
 
 		j.ParentAirport.Wg.Done() //set the task done in the airport working group
-		j.ParentAirport.NbDownloaded = j.ParentAirport.NbDownloaded + 1
-		fmt.Printf("%s downloaded %d / %d \n", j.ParentAirport.Icao, j.ParentAirport.NbDownloaded, len(j.ParentAirport.PdfData))
-
 	}
 }
 
-func downloadPDF(url string, pathFile string, client *http.Client) bool {
-
-	//create the directory
-	os.MkdirAll(filepath.Dir(pathFile), os.ModePerm)
-
-	newFile, err := os.Create(pathFile)
+// downloadPDF downloads the PDF file at url in pathFile.
+// The file is kept only if it is a complete PDF file, see generic.DownloadFile.
+// With validators, the request is conditional and the local file is kept if not modified.
+func downloadPDF(url string, pathFile string, client *http.Client, validators *generic.ManifestEntry) (generic.ManifestEntry, error) {
+	entry, err := generic.DownloadFile(client, url, pathFile, generic.VerifyPdfFile, validators)
 	if err != nil {
-		log.Fatal(err)
-	}
-	defer newFile.Close()
-
-	// HTTP GET request
-	response, err := client.Get(url)
-	defer response.Body.Close()
-
-	// Write bytes from HTTP response to file.
-	// response.Body satisfies the reader interface.
-	// newFile satisfies the writer interface.
-	// That allows us to use io.Copy which accepts
-	// any type that implements reader and writer interface
-	numBytesWritten, err := io.Copy(newFile, response.Body)
-	if err != nil {
-
-		log.Fatal(err)
+		return entry, err
 	}
-	log.Printf("Downloaded %d byte file %s.\n", numBytesWritten, pathFile)
-	return true
+	log.Printf("Downloaded %d byte file %s.\n", entry.Size, pathFile)
+	return entry, nil
 }
 
+// Number of times the download and merge of an airport is tried.
+const maxAirportAttempts = 2
+
 // DownloadAndMergeAiportData will donwload the aiport pdf files (description and charts).
 // In order to save time, will download only the files that are not up to date or not created before.
 // DonwloadAndMergeAirportsData has the capability to retrieve and restart a donwload if:
@@ -71,118 +63,132 @@ func downloadPDF(url string, pathFile string, client *http.Client) bool {
 // DownloadAndMergeAirportData does not download directly the files. Instead it puts the download files
 // in the jobs channel. By this way it is possible to limit more easily the number of http client used to
 // download the data.
-// After download, the pdf data files are merged together in order to create _full pdf file and _chart pdf file.
-// If for any reason the merge fails (mainly for file problem), a new download is performed for all the airport data.
-// This new download is done only one time.
-func DownloadAndMergeAiportData(apt *generic.Airport, jobs *chan *generic.PdfData, docWg *sync.WaitGroup, force bool) {
-
-	//reset the number of pdf files downloaded
-	apt.NbDownloaded = 0
-	//Ensures that we try at worst two times the download
-	if apt.DownloadCount > 1 {
-		//fmt.Println("*******" + apt.icao + " cannot perform the Merge process effciently - stop")
-		log.Fatal("*******" + apt.Icao + " cannot perform the Merge process effciently - stop")
+// After download, if merge is set, the pdf data files are merged together according to the merge profiles
+// (by default, a _full pdf file and a _chart pdf file).
+// If for any reason the download or the merge fails, a new download is performed for all the airport data.
+// This new download is done only one time, then the failure is recorded in the airport.
+// The airport is removed from the docWg waiting group in all cases.
+func DownloadAndMergeAiportData(apt *generic.Airport, jobs *chan *generic.PdfData, docWg *sync.WaitGroup, force bool, merge bool) {
+	defer docWg.Done()
+	//the manifest is saved after each airport, so an interrupted run keeps the downloads done
+	defer saveManifest(apt.AipDocument)
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAirportAttempts; attempt++ {
+		//reset the number of pdf files downloaded
+		apt.NbDownloaded = 0
+
+		if err := DownloadAiportData(apt, jobs, force || attempt > 1); err != nil {
+			apt.AddError(err)
+			return
+		}
+		//wait the waiting group of the airport
+		apt.Wg.Wait()
+
+		//merge the pdf data if everything was done
+		//thanks the Wait, the call of DetermineIsDownloaded is not mandatory.
+		//But it provides a complementary means of verification$
+		if !apt.DetermmineIsDownloaded() {
+			//all the files have not been downloaded. Start again the download...
+			fmt.Println("*******" + apt.Icao + " is not completed. No PDF merge done.")
+			lastErr = fmt.Errorf("%d file(s) not downloaded", len(apt.PdfData)-apt.NbDownloaded)
+			continue
+		}
+
+		fmt.Println("Airport: " + apt.Icao + " all docs downloaded confirmed.")
+		if !merge {
+			return
+		}
+		lastErr = MergeAiportData(apt)
+		if lastErr == nil {
+			//All the airport downloads and merge have been done.
+			return
+		}
+		fmt.Printf("     Problem on Airport: %s %s. \n", apt.Icao, lastErr)
 	}
+	apt.AddError(fmt.Errorf("download and merge failed after %d attempts: %s", maxAirportAttempts, lastErr))
+}
 
-	DownloadAiportData(apt, jobs, force)
-	//wait the waiting group of the airport
-	apt.Wg.Wait()
+// MergeAiportData merges the downloaded pdf files of the airport, according to the merge profiles.
+func MergeAiportData(apt *generic.Airport) error {
+	if len(apt.PdfData) == 0 {
+		log.Printf("No PDF file for %s \n", apt.Icao)
+		return nil
+	}
+	fmt.Printf("     Airport: %s merging files (%d). \n", apt.Icao, len(apt.PdfData))
+	return MergePdfDataOfAiport(apt)
+}
 
-	//merge the pdf data if everything was done
-	//thanks the Wait, the call of DetermineIsDownloaded is not mandatory.
-	//But it provides a complementary means of verification$
-	//Merge only if there is more than one file.
-	if apt.DetermmineIsDownloaded() {
-		fmt.Println("Airport: " + apt.Icao + " all docs downloaded confirmed.")
-		if len(apt.PdfData) > 1 {
-			fmt.Printf("     Airport: %s merging files (%d). \n", apt.Icao, len(apt.PdfData))
-			err := MergePdfDataOfAiport(apt)
-			if err != nil {
-				fmt.Println("     Problem on Airport: %s download again. \n", apt.Icao)
-				DownloadAndMergeAiportData(apt, jobs, docWg, true)
-			} else {
-				//All the airport downloads and merge have been done. The airport can be remove of the waiting group
-				docWg.Done()
-			}
-		} else if len(apt.PdfData) == 1 {
-			//copy the file in the merge directory
-			outPath := apt.AipDocument.DirMergeFiles()
-			outFullMerge := generic.MergedData{FileName: apt.Icao + "_full.pdf", FileDirectory: outPath}
-			opth := filepath.Join(outFullMerge.FileDirectory, outFullMerge.FileName)
-			_, err := Copy(apt.PdfData[0].FilePath, opth)
-			if err != nil {
-				fmt.Println("     Problem with Airport: %s unable to copy in %s. \n", apt.Icao, opth)
-				fmt.Println("       Download file(s) again")
-				DownloadAndMergeAiportData(apt, jobs, docWg, true)
-			} else {
-				apt.MergePdf = append(apt.MergePdf, outFullMerge)
-				docWg.Done()
-			}
+// expectedMergedData provides the merged files which shall be produced for the airport:
+// one per merge profile selecting at least one file.
+func expectedMergedData(apt *generic.Airport) []generic.MergedData {
+	outPath := apt.AipDocument.DirMergeFiles()
+	var merged []generic.MergedData
+	for _, profile := range generic.ConfData.MergeProfileList() {
+		if len(profile.Select(apt.PdfData)) > 0 {
+			merged = append(merged, generic.MergedData{Title: profile.Name, FileName: profile.OutputName(apt.Icao), FileDirectory: outPath})
+		}
+	}
+	return merged
+}
 
+// missingPdfData confirms that each pdf file of the airport is on disk and starts with the PDF header.
+// The download status of the confirmed files is set. It returns the problems found.
+func missingPdfData(apt *generic.Airport) []error {
+	var errs []error
+	for i := range apt.PdfData {
+		pdfD := &apt.PdfData[i]
+		pdfD.ParentAirport = apt
+		err := generic.VerifyPdfFile(pdfD.FilePath)
+		if entry, ok := apt.AipDocument.Manifest().Lookup(pdfD.FilePath); err == nil && ok && !entry.Matches(pdfD.FilePath) {
+			err = fmt.Errorf("%s differs from the manifest", pdfD.FilePath)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", apt.Icao, err))
+			pdfD.DownloadStatus = false
 		} else {
-			log.Printf("No PDF file for %s \n", apt.Icao)
+			pdfD.DownloadStatus = true
 		}
-	} else {
-		//all the files have not been downloaded. Start again the download...
-		fmt.Println("*******" + apt.Icao + " is not completed. No PDF merge done. Start a New download")
-		DownloadAndMergeAiportData(apt, jobs, docWg, true)
 	}
-
+	return errs
 }
 
-func DownloadAiportData(apt *generic.Airport, jobs *chan *generic.PdfData, force bool) {
-
-	di, err := os.Stat(apt.DirDownload())
-	//determine if the target directory exists, or was created before the effective date.
-	// Also, if force is done, all the files will be donwloaded again.
-	if force || os.IsNotExist(err) || di.ModTime().Before(apt.AipDocument.Document().EffectiveDate) {
-		//create the directory
-		os.MkdirAll(apt.DirDownload(), os.ModePerm)
-		//the directory does not exist or is not up to date
-		apt.SetPdfDataListInChannel(jobs)
-
-		//set the directory time to the cuurent date
-		if err := os.Chtimes(apt.DirDownload(), time.Now(), time.Now()); err != nil {
-			log.Fatal(err)
-			panic(err)
-		}
-	} else if err == nil {
-		//if directory exists, then case by case in regard of the file description
-		for i := range apt.PdfData {
-			apt.PdfData[i].ParentAirport = apt
-			filePth := apt.PdfData[i].FilePath
-			fi, err := os.Stat(filePth)
-			if os.IsNotExist(err) {
-				//the files does not exist, download it
-
-				*jobs <- &(apt.PdfData[i])
-				apt.Wg.Add(1) //add to the working group
-			} else if err == nil {
-				//the file exists, check if the file is before the effectiveDate.
-				//As there is one directory by effective Date, there is no specific
-				//ned to check if the file is after the next effective date.
-				//This check is only to be sur that the directory is well up to date
-				if fi.ModTime().Before(apt.AipDocument.Document().EffectiveDate) {
-					*jobs <- &(apt.PdfData[i])
-					apt.Wg.Add(1) //add to the working group
-				} else {
-					apt.PdfData[i].DownloadStatus = true
-					apt.NbDownloaded = apt.NbDownloaded + 1
-				}
-
-			} else if err != nil {
-				//there is an error... lets go for a panic
-				log.Fatal(err)
-				panic(err)
-			}
+// DownloadAiportData puts in the jobs channel the pdf files of the airport which shall be downloaded.
+// A file is downloaded if force is set, or if the manifest of the edition does not confirm
+// the local file (missing entry, other url, size or SHA-256 mismatch).
+// An error is returned if the download directory cannot be created.
+func DownloadAiportData(apt *generic.Airport, jobs *chan *generic.PdfData, force bool) error {
+	//create the directory
+	if err := os.MkdirAll(apt.DirDownload(), os.ModePerm); err != nil {
+		return err
+	}
+
+	manifest := apt.AipDocument.Manifest()
+	urlDir := apt.AipDocument.Document().FullURLDir
+	for i := range apt.PdfData {
+		pdfD := &apt.PdfData[i]
+		pdfD.ParentAirport = apt
+		if !force && manifest.IsUpToDate(pdfD.FilePath, urlDir+pdfD.Link) {
+			pdfD.DownloadStatus = true
+			pdfD.Err = nil
+			apt.AddDownloaded()
+		} else {
+			apt.Wg.Add(1) //add to the working group
+			*jobs <- pdfD
 		}
-	} else {
-		//there is an error with the directory. Lets go for a panic
-		log.Fatal(err)
-		panic(err)
 	}
+	return nil
 }
 
+// saveManifest saves the manifest of the document, a failure is only logged
+// as the files will be downloaded again during the next run.
+func saveManifest(doc generic.IAipDocument) {
+	if err := doc.Manifest().Save(); err != nil {
+		log.Printf("Unable to save the manifest of %s: %s \n", doc.DirMainDownload(), err)
+	}
+}
+
+// Copy copies the src file in dst. The dst file is written atomically.
 func Copy(src string, dst string) (int64, error) {
 	src_file, err := os.Open(src)
 	if err != nil {
@@ -199,10 +205,11 @@ func Copy(src string, dst string) (int64, error) {
 		return 0, fmt.Errorf("%s is not a regular file", src)
 	}
 
-	dst_file, err := os.Create(dst)
-	if err != nil {
-		return 0, err
-	}
-	defer dst_file.Close()
-	return io.Copy(dst_file, src_file)
+	var n int64
+	err = generic.WriteFileAtomic(dst, func(w io.Writer) error {
+		var err error
+		n, err = io.Copy(w, src_file)
+		return err
+	}, nil)
+	return n, err
 }
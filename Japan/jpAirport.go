@@ -8,7 +8,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"io"
 
 	"path/filepath"
 
@@ -26,69 +25,70 @@ type JpAirport struct {
 	This webpage will be used to retrieve all the relevant information.
 	The path to the downloaded file will be indicated in the airport.htmlPage field.
 */
-func (apt *JpAirport) DownloadPage(cl *http.Client) { //, aipURLDir string) {
+func (apt *JpAirport) DownloadPage(cl *http.Client) error { //, aipURLDir string) {
 
 	var indexURL = apt.AipDocument.Document().FullURLDir + apt.Link // aipURLDir + apt.link
+	filePth := filepath.Join(apt.DirDownload(), apt.Icao+".html")
+
+	// HTTP GET request, conditional if the local copy is known by the manifest.
+	// A 304 answer confirms the local copy, the body is not transferred.
 	fmt.Println("     Download the airport page: " + indexURL)
-	resp, err := cl.Get(indexURL)
-	if err != nil {
-		log.Fatal(err)
+	validators := apt.AipDocument.Manifest().Validators(filePth, indexURL)
+	resp, err := generic.GetConditional(cl, indexURL, validators)
+	if err == generic.ErrNotModified {
+		log.Printf("Airport %s - page %s not modified, local copy is good %s.\n", apt.Icao, indexURL, filePth)
+		apt.HtmlPage = filePth
+		return nil
+	} else if err != nil {
+		return err
 	}
 
 	defer resp.Body.Close()
 
-	// HTTP GET request
-
-	filePth := filepath.Join(apt.DirDownload(), apt.Icao+".html")
-
-	if apt.ShouldIDownloadHtmlPage(filePth, resp.ContentLength) {
-		//create the directory
-		os.MkdirAll(apt.DirDownload(), os.ModePerm)
-		newFile, err := os.Create(filePth)
-		// Write bytes from HTTP response to file.
-		// response.Body satisfies the reader interface.
-		// newFile satisfies the writer interface.
-		// That allows us to use io.Copy which accepts
-		// any type that implements reader and writer interface
-
-		numBytesWritten, err := io.Copy(newFile, resp.Body)
+	if apt.ShouldIDownloadHtmlPage(filePth, indexURL, resp.ContentLength) {
+		// Write bytes from HTTP response to a temporary file, renamed
+		// as the airport page once complete, and record it in the manifest.
+		entry, err := generic.SaveResponse(resp, indexURL, filePth, nil)
 		if err != nil {
-			log.Printf("Unable to write the webpage %s in directory %s \n", indexURL, filePth)
-			log.Fatal(err)
+			return fmt.Errorf("unable to write the webpage %s in %s: %s", indexURL, filePth, err)
 		}
-		log.Printf("Airport %s - downloaded %d byte file %s.\n", apt.Icao, numBytesWritten, filePth)
+		apt.AipDocument.Manifest().Record(filePth, entry)
+		log.Printf("Airport %s - downloaded %d byte file %s.\n", apt.Icao, entry.Size, filePth)
 	} else {
 		log.Printf("Airport %s - page %s not saved, local copy is good %s.\n", apt.Icao, indexURL, filePth)
 	}
 	apt.HtmlPage = filePth
+	return nil
 }
 
 // GetPDFFromHTML will retrieve the PDF information (which will be downloaded later) in a HTML
 // indicated by combination of the fullURLDir and the content of the Airport.link.
 // The function will populate the PdfData table of the Airport.
+// Each chart is classified with its title (the text of the link) and the label of its row.
 // This approach allows a simple way to conbsider the fact that the main directory evolves for each new AIP vesion.
 // As there is the web pages do not contain a direct link to the main PDF file, a dedicated entry
 // is done during the process.
 // There is no need to sort the identified PDF files. The natural sorting, done by the data recovery ensures
 // the correct order. The name of the files is not sufficient to set them in the corect order
-func (apt *JpAirport) GetPDFFromHTML(cl *http.Client, aipURLDir string) {
+// When the airport page has been downloaded (see DownloadPage), the local copy is used.
+func (apt *JpAirport) GetPDFFromHTML(cl *http.Client, aipURLDir string) error {
 
 	apt.DownloadCount = 0 //reinit the download counter
 	var indexUrl = aipURLDir + apt.Link
-	divWord := `div[id="` + apt.Icao + "-AD-2.24" + `"]`
-
-	fmt.Println("     Retrieve PDF pathes from: " + indexUrl)
-	resp, err := cl.Get(indexUrl)
-	if err != nil {
-		log.Fatal(err)
+	chartsId, _ := apt.sectionId("2.24")
+	divWord := `div[id="` + chartsId + `"]`
+
+	var doc *goquery.Document
+	var err error
+	if apt.HtmlPage != "" {
+		fmt.Println("     Retrieve PDF pathes from: " + apt.HtmlPage)
+		doc, err = apt.loadHtmlPage()
+	} else {
+		fmt.Println("     Retrieve PDF pathes from: " + indexUrl)
+		doc, err = getHtmlDocument(cl, indexUrl)
 	}
-
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		fmt.Println("No url found for airports extraction")
-		log.Fatal(err)
+		return fmt.Errorf("PDF list extraction: %s", err)
 	}
 
 	//create and retrieve the main PDF page
@@ -101,12 +101,41 @@ func (apt *JpAirport) GetPDFFromHTML(cl *http.Client, aipURLDir string) {
 		divhtml.Find("a").Each(func(index int, ahtml *goquery.Selection) {
 			pdfLink, ext := ahtml.Attr("href")
 			if ext {
-				apt.AddPdfData(apt.getChartPDFFile(pdfLink))
+				pdf := apt.getChartPDFFile(pdfLink)
+				pdf.Title = strings.Join(strings.Fields(ahtml.Text()), " ")
+				if pdf.Title == "" {
+					pdf.Title = pdf.FileName
+				}
+				pdf.Classify(chartRowLabel(ahtml))
+				apt.AddPdfData(pdf)
 				//apt.PdfData = append(apt.PdfData, apt.getChartPDFFile(pdfLink))
 			}
 
 		})
 	})
+	return nil
+}
+
+// chartRowLabel provides the label of the row of a chart link in the chart list
+// (ex: Instrument Approach Chart), that is the text of the other cells of the row.
+func chartRowLabel(ahtml *goquery.Selection) string {
+	var label []string
+	ahtml.Closest("tr").Find("td").Each(func(i int, td *goquery.Selection) {
+		if td.Find("a").Length() == 0 {
+			label = append(label, strings.Join(strings.Fields(td.Text()), " "))
+		}
+	})
+	return strings.Join(label, " ")
+}
+
+// loadHtmlPage parses the local copy of the airport page.
+func (apt *JpAirport) loadHtmlPage() (*goquery.Document, error) {
+	f, err := os.Open(apt.HtmlPage)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return goquery.NewDocumentFromReader(f)
 }
 
 // mainPDFFile creates the path to the main PDF as there is no associated link in the webpage
@@ -115,12 +144,21 @@ func (apt *JpAirport) getTxtPDFFile() generic.PdfData {
 	pdfTxt := generic.PdfData{}
 	pdfTxt.ParentAirport = &apt.Airport
 	pdfTxt.DataContentType = "Text"
-	pdfTxt.Link = fmt.Sprintf("pdf/JP-AD-2-%s-en-JP.pdf", apt.Icao)
-	pdfTxt.FileName = fmt.Sprintf("JP-AD-2-%s-en-JP.pdf", apt.Icao)
+	pdfTxt.Title = fmt.Sprintf("%s AD %s", apt.Icao, apt.adPart())
+	pdfTxt.Link = fmt.Sprintf("pdf/JP-AD-%s-%s-en-JP.pdf", apt.adPart(), apt.Icao)
+	pdfTxt.FileName = fmt.Sprintf("JP-AD-%s-%s-en-JP.pdf", apt.adPart(), apt.Icao)
 
 	return pdfTxt
 }
 
+// adPart provides the part of the AIP describing the airport: 3 for a heliport, else 2.
+func (apt *JpAirport) adPart() string {
+	if apt.AirportType == generic.HeliportType {
+		return "3"
+	}
+	return "2"
+}
+
 func (apt *JpAirport) getChartPDFFile(partialLink string) generic.PdfData {
 	pdfChart := generic.PdfData{}
 	pdfChart.ParentAirport = &apt.Airport
@@ -143,11 +181,13 @@ func (apt *JpAirport) GetNavaids() (map[string]generic.Navaid, int) {
 	f, err := os.Open(apt.HtmlPage)
 	if err != nil {
 		log.Println("Unable to open " + apt.HtmlPage)
+		return nil, 0
 	}
+	defer f.Close()
 	doc, err := goquery.NewDocumentFromReader(f)
 	if err != nil {
-		fmt.Println("Unable to parse")
-		log.Fatal(err)
+		log.Printf("Unable to parse %s: %s \n", apt.HtmlPage, err)
+		return nil, 0
 	}
 
 	sel := doc.Find(divId).First()
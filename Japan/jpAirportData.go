@@ -0,0 +1,471 @@
+package japan
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+// GetAirportData fills the airport with the sections of the local copy of the airport page
+// (see DownloadPage): AD 2.2 reference data, AD 2.10 obstacles, AD 2.12 and AD 2.13 runways,
+// AD 2.17 airspaces and AD 2.18 communication facilities.
+// For a heliport, the corresponding AD 3 sections are used (see sectionId).
+// The page is parsed once. A section which cannot be parsed is reported in the returned error,
+// the other sections are kept.
+func (apt *JpAirport) GetAirportData() error {
+	if apt.HtmlPage == "" {
+		return fmt.Errorf("airport data extraction: html file is not downloaded")
+	}
+	doc, err := apt.loadHtmlPage()
+	if err != nil {
+		return fmt.Errorf("airport data extraction: %s", err)
+	}
+
+	//the AD 2.2 reference data are loaded first: the aerodrome airspaces centred on the ARP use them
+	loaders := []func(*goquery.Document) error{
+		apt.loadAdminData, apt.loadObstacles, apt.loadRunways, apt.loadDeclaredDistances,
+		apt.loadAirspaces, apt.loadComData}
+	if apt.AirportType == generic.HeliportType {
+		//a heliport has no runway, its final approach and take-off areas are not converted
+		loaders = []func(*goquery.Document) error{
+			apt.loadAdminData, apt.loadObstacles, apt.loadAirspaces, apt.loadComData}
+	}
+
+	var problems []string
+	for _, load := range loaders {
+		if err := load(doc); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("airport data extraction: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// heliportSections gives the AD 3 section of a heliport corresponding to the AD 2 section of an aerodrome.
+var heliportSections = map[string]string{
+	"2.2":  "3.2",
+	"2.10": "3.10",
+	"2.17": "3.16",
+	"2.18": "3.17",
+	"2.24": "3.23",
+}
+
+// sectionId provides the id of the AD 2.x section (ex: "2.2") in the airport page (ex: RJTT-AD-2.2).
+// For a heliport, it is the id of the corresponding AD 3 section (ex: RJTI-AD-3.2).
+// The second value is false if the heliports have no corresponding section.
+func (apt *JpAirport) sectionId(section string) (string, bool) {
+	if apt.AirportType == generic.HeliportType {
+		s, ok := heliportSections[section]
+		return fmt.Sprintf("%s-AD-%s", apt.Icao, s), ok
+	}
+	return fmt.Sprintf("%s-AD-%s", apt.Icao, section), true
+}
+
+// airportSection provides the div of the AD 2.x section (ex: "2.2") of the airport page.
+func (apt *JpAirport) airportSection(doc *goquery.Document, section string) (*goquery.Selection, error) {
+	id, ok := apt.sectionId(section)
+	if !ok {
+		return nil, fmt.Errorf("no AD %s section for the heliport %s", section, apt.Icao)
+	}
+	div := doc.Find(fmt.Sprintf(`div[id="%s"]`, id)).First()
+	if div.Length() == 0 {
+		return nil, fmt.Errorf("no %s section in %s", id, apt.HtmlPage)
+	}
+	return div, nil
+}
+
+// loadAdminData fills the AdminData of the airport with the AD 2.2 section
+// (aerodrome geographical and administrative data).
+// The rows are identified by their label. The rows which cannot be converted are reported
+// in the returned error, the other ones are kept.
+func (apt *JpAirport) loadAdminData(doc *goquery.Document) error {
+	div, err := apt.airportSection(doc, "2.2")
+	if err != nil {
+		return fmt.Errorf("AD 2.2: %s", err)
+	}
+
+	var problems []string
+	div.Find("tr").Each(func(index int, tr *goquery.Selection) {
+		tds := tr.Find("td")
+		if tds.Length() < 2 {
+			return
+		}
+		//the label is in the column before the value, the first column is the item number
+		label := strings.ToUpper(tds.Eq(tds.Length() - 2).Text())
+		if err := apt.setAdminDataItem(label, cellText(tds.Last())); err != nil {
+			problems = append(problems, err.Error())
+		}
+	})
+
+	if ft, ok := apt.AdminData.Elevation.InFeet(); ok {
+		apt.AdminData.ArpCoord.Altitude = ft
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("AD 2.2: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (apt *JpAirport) setAdminDataItem(label string, value string) error {
+	switch {
+	case strings.Contains(label, "GEOID"):
+		//Exemple: 37m
+		m, err := generic.ParseMeasure(value)
+		if err != nil {
+			return fmt.Errorf("geoid undulation: %s", err)
+		}
+		apt.AdminData.Geoid_undulation = m
+	case strings.Contains(label, "ARP"):
+		//Exemple: 353312N 1394652E Intersection of RWY16R/34L and RWY04/22
+		loc := coordinatesPairRe.FindStringIndex(value)
+		if loc == nil {
+			return fmt.Errorf("ARP: no coordinates in %s", value)
+		}
+		pos, err := generic.ParseGeoPosition(value[loc[0]:loc[1]])
+		if err != nil {
+			return fmt.Errorf("ARP: %s", err)
+		}
+		apt.AdminData.ArpCoord.Latitude = pos.Latitude
+		apt.AdminData.ArpCoord.Longitude = pos.Longitude
+		apt.AdminData.ArpSite = strings.Join(strings.Fields(value[loc[1]:]), " ")
+	case strings.Contains(label, "ELEVATION"):
+		//Exemple: 21ft / 30.4°C (AUG), the reference temperature is not kept
+		m, err := generic.ParseMeasure(strings.Split(value, "/")[0])
+		if err != nil {
+			return fmt.Errorf("elevation: %s", err)
+		}
+		apt.AdminData.Elevation = m
+	case strings.Contains(label, "MAG VAR"):
+		//Exemple: 8°W (2020) / 0.1°W
+		parts := strings.SplitN(value, "/", 2)
+		v, err := generic.ConvertMagVariationToFloat(parts[0])
+		if err != nil {
+			return fmt.Errorf("magnetic variation: %s", err)
+		}
+		apt.AdminData.Mag_var = v
+		if len(parts) == 2 {
+			c, err := generic.ConvertMagVariationToFloat(parts[1])
+			if err != nil {
+				return fmt.Errorf("magnetic variation annual change: %s", err)
+			}
+			apt.AdminData.Mag_annualchange = c
+		}
+	case strings.Contains(label, "TRAFFIC"):
+		//Exemple: IFR/VFR
+		apt.AdminData.Traffic_types = nil
+		for _, t := range strings.FieldsFunc(value, func(r rune) bool { return r == '/' || r == ',' }) {
+			if t = strings.TrimSpace(t); t != "" {
+				apt.AdminData.Traffic_types = append(apt.AdminData.Traffic_types, t)
+			}
+		}
+	}
+	return nil
+}
+
+// comDataColumns is the number of columns of the AD 2.18 table:
+// service designation, callsign, frequency, hours of operation and remarks.
+const comDataColumns = 5
+
+// loadComData fills the Com list of the airport with the AD 2.18 section
+// (ATS communication facilities).
+// When a cell spans several rows (ex: the same service with several frequencies),
+// the following rows have less cells: the missing leading cells are taken from the previous row.
+func (apt *JpAirport) loadComData(doc *goquery.Document) error {
+	div, err := apt.airportSection(doc, "2.18")
+	if err != nil {
+		return fmt.Errorf("AD 2.18: %s", err)
+	}
+
+	apt.Com = []generic.ComData{}
+	var cells, previous []string
+	div.Find("tbody tr").Each(func(index int, tr *goquery.Selection) {
+		tds := tr.Find("td")
+		if tds.Length() == 0 || tds.Length() > comDataColumns {
+			return
+		}
+		cells = make([]string, comDataColumns)
+		offset := comDataColumns - tds.Length()
+		copy(cells, previous)
+		tds.Each(func(i int, td *goquery.Selection) {
+			cells[offset+i] = strings.Join(strings.Fields(td.Text()), " ")
+		})
+		previous = cells
+
+		//the rows with the column numbers or titles are not communication facilities
+		if _, err := strconv.Atoi(cells[0]); err == nil || cells[0] == "" {
+			return
+		}
+		freqs := generic.ParseFrequencies(cells[2])
+		if len(freqs) == 0 {
+			return
+		}
+		apt.Com = append(apt.Com, generic.ComData{
+			Service:         cells[0],
+			CallSign:        cells[1],
+			Frequencies:     freqs,
+			OperationsHours: cells[3],
+			Remarks:         cells[4],
+		})
+	})
+	return nil
+}
+
+var (
+	coordinatesRe = regexp.MustCompile(`[0-9]{6}(?:\.[0-9]+)?[NS]`)
+	classLetterRe = regexp.MustCompile(`\b[A-G]\b`)
+)
+
+var numberRe = regexp.MustCompile(`[0-9]+(?:\.[0-9]+)?`)
+
+// getDistancesFromTextOfjpAirportData extracts all the distances of a text (ex: 3000 x 60).
+// The values are in meters, unless the text indicates feet.
+func getDistancesFromTextOfjpAirportData(t string) []generic.Measure {
+	unit := "M"
+	if strings.Contains(strings.ToUpper(t), "FT") {
+		unit = "FT"
+	}
+	var dists []generic.Measure
+	for _, n := range numberRe.FindAllString(t, -1) {
+		v, err := strconv.ParseFloat(n, 32)
+		if err == nil {
+			dists = append(dists, generic.Measure{Value: float32(v), Unit: unit})
+		}
+	}
+	return dists
+}
+
+// loadRunways fills the Runways of the airport with the AD 2.12 section (runway physical characteristics).
+// Only the rows starting with a runway designator are considered. The used columns are:
+// designator, true bearing, dimensions, strength (PCN) and surface, threshold coordinates
+// and threshold elevation.
+func (apt *JpAirport) loadRunways(doc *goquery.Document) error {
+	div, err := apt.airportSection(doc, "2.12")
+	if err != nil {
+		return fmt.Errorf("AD 2.12: %s", err)
+	}
+
+	apt.Runways = []generic.Runway{}
+	var problems []string
+	div.Find("tr").Each(func(index int, tr *goquery.Selection) {
+		var cells []string
+		tr.Find("td").Each(func(i int, td *goquery.Selection) {
+			cells = append(cells, cellText(td))
+		})
+		if len(cells) < 6 {
+			return
+		}
+		designator, ok := generic.RunwayDesignator(cells[0])
+		if !ok {
+			return
+		}
+		rwy, err := getRunwayFromCellsOfjpAirportData(designator, cells)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("RWY %s %s", designator, err))
+		}
+		apt.Runways = append(apt.Runways, rwy)
+	})
+
+	if len(problems) > 0 {
+		return fmt.Errorf("AD 2.12: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// getRunwayFromCellsOfjpAirportData converts a row of the AD 2.12 table.
+// The values which cannot be converted are left empty and reported in the error.
+func getRunwayFromCellsOfjpAirportData(designator string, cells []string) (generic.Runway, error) {
+	rwy := generic.Runway{Designator: designator}
+	var problems []string
+
+	//Exemple: 157.64°
+	if brg, err := strconv.ParseFloat(numberRe.FindString(cells[1]), 32); err == nil {
+		rwy.TrueBearing = float32(brg)
+	} else {
+		problems = append(problems, "true bearing: "+cells[1])
+	}
+
+	//Exemple: 3000 x 60
+	if dims := getDistancesFromTextOfjpAirportData(cells[2]); len(dims) >= 2 {
+		rwy.Length, rwy.Width = dims[0], dims[1]
+	} else {
+		problems = append(problems, "dimensions: "+cells[2])
+	}
+
+	//Exemple: PCN 125/R/A/W/T ASPH
+	pcnre := regexp.MustCompile(`PCN\s*[0-9]+\s*/\s*[RF]\s*/\s*[A-D]\s*/\s*[W-Z]\s*/\s*[TU]`)
+	rwy.Surface = cells[3]
+	rwy.Pcn = strings.Join(strings.Fields(pcnre.FindString(cells[3])), "")
+
+	//Exemple: 353336.84N 1394650.64E
+	if pos, err := generic.ParseGeoPosition(cells[4]); err == nil {
+		rwy.Threshold.Latitude = pos.Latitude
+		rwy.Threshold.Longitude = pos.Longitude
+	} else {
+		problems = append(problems, "threshold: "+err.Error())
+	}
+
+	//Exemple: THR 20ft / TDZ 21ft, the touchdown zone elevation is not kept
+	elev, err := generic.ParseMeasure(cells[5])
+	if err == nil {
+		rwy.ThresholdElevation = elev
+		if ft, ok := elev.InFeet(); ok {
+			rwy.Threshold.Altitude = ft
+		}
+	} else {
+		problems = append(problems, "threshold elevation: "+err.Error())
+	}
+
+	if len(problems) > 0 {
+		return rwy, fmt.Errorf("%s", strings.Join(problems, ", "))
+	}
+	return rwy, nil
+}
+
+// loadDeclaredDistances completes the Runways of the airport with the AD 2.13 section
+// (TORA, TODA, ASDA and LDA). A runway only known by the AD 2.13 section is added.
+func (apt *JpAirport) loadDeclaredDistances(doc *goquery.Document) error {
+	div, err := apt.airportSection(doc, "2.13")
+	if err != nil {
+		return fmt.Errorf("AD 2.13: %s", err)
+	}
+
+	div.Find("tr").Each(func(index int, tr *goquery.Selection) {
+		var cells []string
+		tr.Find("td").Each(func(i int, td *goquery.Selection) {
+			cells = append(cells, cellText(td))
+		})
+		if len(cells) < 5 {
+			return
+		}
+		designator, ok := generic.RunwayDesignator(cells[0])
+		if !ok {
+			return
+		}
+		rwy := apt.Runway(designator)
+		if rwy == nil {
+			apt.Runways = append(apt.Runways, generic.Runway{Designator: designator})
+			rwy = &apt.Runways[len(apt.Runways)-1]
+		}
+		for i, d := range []*generic.Measure{&rwy.Tora, &rwy.Toda, &rwy.Asda, &rwy.Lda} {
+			//a distance may be not published (ex: NIL)
+			if dists := getDistancesFromTextOfjpAirportData(cells[i+1]); len(dists) > 0 {
+				*d = dists[0]
+			}
+		}
+	})
+	return nil
+}
+
+// loadAirspaces fills the Airspaces of the airport with the AD 2.17 section (air traffic services airspace).
+// The rows are identified by their label; each designation row starts a new airspace.
+// A circle centred on the ARP uses the coordinates of the AD 2.2 section, so it shall be loaded first;
+// without ARP, the circle is reported and has no boundary.
+func (apt *JpAirport) loadAirspaces(doc *goquery.Document) error {
+	div, err := apt.airportSection(doc, "2.17")
+	if err != nil {
+		return fmt.Errorf("AD 2.17: %s", err)
+	}
+
+	apt.Airspaces = []generic.Airspace{}
+	var problems []string
+	var asp *generic.Airspace
+	div.Find("tr").Each(func(index int, tr *goquery.Selection) {
+		tds := tr.Find("td")
+		if tds.Length() < 2 {
+			return
+		}
+		label := strings.ToUpper(tds.Eq(tds.Length() - 2).Text())
+		value := cellText(tds.Last())
+		if strings.Contains(label, "DESIGNATION") {
+			apt.Airspaces = append(apt.Airspaces, generic.Airspace{})
+			asp = &apt.Airspaces[len(apt.Airspaces)-1]
+			if err := apt.setAirspaceDesignation(asp, cellLines(tds.Last())); err != nil {
+				problems = append(problems, err.Error())
+			}
+			return
+		}
+		if asp == nil {
+			return
+		}
+
+		switch {
+		case strings.Contains(label, "VERTICAL"):
+			//Exemple: 3000FT ALT / SFC
+			limits := generic.ParseVerticalLimits(value)
+			if len(limits) < 2 {
+				problems = append(problems, fmt.Sprintf("%s vertical limits: %s", asp.Name, value))
+				return
+			}
+			asp.Upper, asp.Lower = limits[0], limits[1]
+		case strings.Contains(label, "CLASS"):
+			//Exemple: D
+			if m := classLetterRe.FindString(value); m != "" {
+				asp.Class = m
+			}
+		case strings.Contains(label, "CALL") || strings.Contains(label, "LANGUAGE"):
+			//Exemple: Tokyo Tower / English, Japanese
+			parts := strings.SplitN(value, "/", 2)
+			asp.CallSign = strings.TrimSpace(parts[0])
+			asp.Unit = asp.CallSign
+			if len(parts) == 2 {
+				asp.Language = strings.TrimSpace(parts[1])
+			}
+		case strings.Contains(label, "TRANSITION"):
+			//Exemple: 14000ft
+			if ta, err := generic.ParseVerticalLimit(value); err == nil {
+				asp.TransitionAltitude = ta
+			} else {
+				problems = append(problems, fmt.Sprintf("%s transition altitude: %s", asp.Name, err))
+			}
+		case strings.Contains(label, "REMARK"):
+			asp.Remarks = value
+		}
+	})
+
+	if len(problems) > 0 {
+		return fmt.Errorf("AD 2.17: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// setAirspaceDesignation sets the name, the type and the boundary of an aerodrome airspace.
+// The name is the first line, or the text before a colon (ex: Tokyo CTR: A circle ...).
+func (apt *JpAirport) setAirspaceDesignation(asp *generic.Airspace, lines []string) error {
+	if len(lines) == 0 {
+		return fmt.Errorf("empty designation")
+	}
+	lateral := lines[1:]
+	asp.Name = lines[0]
+	if i := strings.Index(lines[0], ":"); i >= 0 {
+		asp.Name = strings.TrimSpace(lines[0][:i])
+		lateral = append([]string{lines[0][i+1:]}, lateral...)
+	}
+	asp.Type = airspaceTypeRe.FindString(strings.ToUpper(asp.Name))
+
+	text := strings.Join(lateral, " ")
+	up := strings.ToUpper(text)
+	var err error
+	if strings.Contains(up, "CIRCLE") && strings.Contains(up, "ARP") && !coordinatesRe.MatchString(text) {
+		//Exemple: A circle, radius 5NM centred on ARP
+		arp := apt.AdminData.ArpCoord
+		if arp.Latitude == 0 && arp.Longitude == 0 {
+			return fmt.Errorf("%s lateral limits: circle centred on an unknown ARP", asp.Name)
+		}
+		var radius float32
+		if radius, err = generic.ParseRadius(up); err == nil {
+			asp.Boundary = generic.Circle(apt.AdminData.ArpCoord, radius)
+		}
+	} else {
+		asp.Boundary, err = generic.ParseLateralLimits(text)
+	}
+	if err != nil {
+		return fmt.Errorf("%s lateral limits: %s", asp.Name, err)
+	}
+	return nil
+}
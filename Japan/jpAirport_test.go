@@ -0,0 +1,290 @@
+package japan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/NagoDede/aipdownloader/generic"
+	"github.com/PuerkitoBio/goquery"
+)
+
+// newPageAirport creates an airport whose local page is the indicated body.
+func newPageAirport(t *testing.T, icao string, body string) *JpAirport {
+	f, err := ioutil.TempFile("", icao+"*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	fmt.Fprintf(f, "<html><body>%s</body></html>", body)
+	f.Close()
+
+	apt := &JpAirport{}
+	apt.Icao = icao
+	apt.HtmlPage = f.Name()
+	return apt
+}
+
+// pageDocument parses the indicated body as an airport page.
+func pageDocument(t *testing.T, body string) *goquery.Document {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader("<html><body>" + body + "</body></html>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func assertNear(t *testing.T, what string, got float32, want float64) {
+	t.Helper()
+	if math.Abs(float64(got)-want) > 1e-3 {
+		t.Errorf("%s: got %f, want %f", what, got, want)
+	}
+}
+
+func TestLoadAdminData(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	if err := apt.loadAdminData(pageDocument(t, fmt.Sprintf(mockAdminDataSection, "RJTT", "RJTT"))); err != nil {
+		t.Fatal(err)
+	}
+
+	ad := apt.AdminData
+	assertNear(t, "latitude", ad.ArpCoord.Latitude, 35+33.0/60+12.0/3600)
+	assertNear(t, "longitude", ad.ArpCoord.Longitude, 139+46.0/60+52.0/3600)
+	assertNear(t, "altitude", ad.ArpCoord.Altitude, 21)
+	if ad.ArpSite != "Intersection of RWY16R/34L and RWY04/22" {
+		t.Errorf("unexpected ARP site %q", ad.ArpSite)
+	}
+	if ad.Elevation.Value != 21 || ad.Elevation.Unit != "FT" {
+		t.Errorf("unexpected elevation %v", ad.Elevation)
+	}
+	if ad.Geoid_undulation.Value != 37 || ad.Geoid_undulation.Unit != "M" {
+		t.Errorf("unexpected geoid undulation %v", ad.Geoid_undulation)
+	}
+	assertNear(t, "magnetic variation", ad.Mag_var, -8)
+	assertNear(t, "annual change", ad.Mag_annualchange, -0.1)
+	if strings.Join(ad.Traffic_types, ",") != "IFR,VFR" {
+		t.Errorf("unexpected traffic types %v", ad.Traffic_types)
+	}
+}
+
+func TestLoadAdminDataKeepsValidRows(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJSA"
+	doc := pageDocument(t, `<div id="RJSA-AD-2.2"><table><tbody>
+<tr><td>1</td><td>ARP coordinates and site at AD</td><td>404401.5S 1404124W</td></tr>
+<tr><td>3</td><td>Elevation/Reference temperature</td><td>198m / 27°C</td></tr>
+<tr><td>4</td><td>Geoid undulation at AD ELEV PSN</td><td>Nil</td></tr>
+<tr><td>5</td><td>MAG VAR/Annual change</td><td>9°30'E (2015) / 0°</td></tr>
+</tbody></table></div>`)
+
+	err := apt.loadAdminData(doc)
+	if err == nil || !strings.Contains(err.Error(), "geoid") {
+		t.Errorf("expected a geoid undulation error, got %v", err)
+	}
+
+	ad := apt.AdminData
+	assertNear(t, "latitude", ad.ArpCoord.Latitude, -(40 + 44.0/60 + 1.5/3600))
+	assertNear(t, "longitude", ad.ArpCoord.Longitude, -(140 + 41.0/60 + 24.0/3600))
+	assertNear(t, "altitude", ad.ArpCoord.Altitude, 198*3.28084)
+	assertNear(t, "magnetic variation", ad.Mag_var, 9.5)
+	assertNear(t, "annual change", ad.Mag_annualchange, 0)
+}
+
+func TestLoadAdminDataRejectsShortCoordinates(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJSA"
+	doc := pageDocument(t, `<div id="RJSA-AD-2.2"><table><tbody>
+<tr><td>1</td><td>ARP coordinates and site at AD</td><td>5N 1404124E</td></tr>
+</tbody></table></div>`)
+
+	err := apt.loadAdminData(doc)
+	if err == nil || !strings.Contains(err.Error(), "ARP") {
+		t.Errorf("expected an ARP error, got %v", err)
+	}
+	if apt.AdminData.ArpCoord != (generic.GeoPosition{}) {
+		t.Errorf("unexpected ARP %+v", apt.AdminData.ArpCoord)
+	}
+}
+
+func TestLoadComData(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	if err := apt.loadComData(pageDocument(t, fmt.Sprintf(mockComDataSection, "RJTT", "RJTT"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apt.Com) != 3 {
+		t.Fatalf("expected 3 communication facilities, got %+v", apt.Com)
+	}
+	twr := apt.Com[0]
+	if twr.Service != "TWR" || twr.CallSign != "Tokyo Tower" || twr.OperationsHours != "H24" || twr.Remarks != "Primary" {
+		t.Errorf("unexpected TWR %+v", twr)
+	}
+	if len(twr.Frequencies) != 2 || twr.Frequencies[1].Value != 124.35 || twr.Frequencies[1].Unit != "MHZ" {
+		t.Errorf("unexpected TWR frequencies %v", twr.Frequencies)
+	}
+	//the second TWR row inherits the service and the callsign
+	if apt.Com[1].Service != "TWR" || apt.Com[1].CallSign != "Tokyo Tower" || apt.Com[1].Remarks != "Military" {
+		t.Errorf("unexpected second TWR row %+v", apt.Com[1])
+	}
+	if apt.Com[2].Service != "ATIS" || apt.Com[2].Frequencies[0].Value != 128.8 {
+		t.Errorf("unexpected ATIS %+v", apt.Com[2])
+	}
+}
+
+func TestLoadRunways(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	doc := pageDocument(t, fmt.Sprintf(mockRunwaysSection, "RJTT", "RJTT", "RJTT", "RJTT"))
+	if err := apt.loadRunways(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := apt.loadDeclaredDistances(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apt.Runways) != 2 {
+		t.Fatalf("expected 2 runways, got %+v", apt.Runways)
+	}
+	rwy := apt.Runway("16R")
+	if rwy == nil {
+		t.Fatal("RWY 16R not found")
+	}
+	assertNear(t, "true bearing", rwy.TrueBearing, 157.64)
+	if rwy.Length.Value != 3000 || rwy.Width.Value != 60 || rwy.Length.Unit != "M" {
+		t.Errorf("unexpected dimensions %v x %v", rwy.Length, rwy.Width)
+	}
+	if rwy.Pcn != "PCN125/R/A/W/T" || !strings.Contains(rwy.Surface, "ASPH") {
+		t.Errorf("unexpected surface %q PCN %q", rwy.Surface, rwy.Pcn)
+	}
+	assertNear(t, "threshold latitude", rwy.Threshold.Latitude, 35+33.0/60+36.84/3600)
+	assertNear(t, "threshold longitude", rwy.Threshold.Longitude, 139+46.0/60+50.64/3600)
+	if rwy.ThresholdElevation.Value != 20 || rwy.Threshold.Altitude != 20 {
+		t.Errorf("unexpected threshold elevation %v", rwy.ThresholdElevation)
+	}
+	if rwy.Tora.Value != 3000 || rwy.Toda.Value != 3060 || rwy.Asda.Value != 3000 || rwy.Lda.Value != 3000 {
+		t.Errorf("unexpected declared distances %v %v %v %v", rwy.Tora, rwy.Toda, rwy.Asda, rwy.Lda)
+	}
+	if lda := apt.Runway("34L").Lda; lda.Value != 2700 || lda.Unit != "M" {
+		t.Errorf("unexpected RWY 34L LDA %v", lda)
+	}
+
+	//a truncated threshold position is reported, not converted
+	_, err := getRunwayFromCellsOfjpAirportData("16R", []string{"16R", "157.64°", "3000 x 60", "PCN 125/R/A/W/T ASPH", "5N 1394650.64E", "THR 20ft"})
+	if err == nil || !strings.Contains(err.Error(), "threshold") {
+		t.Errorf("expected a threshold error, got %v", err)
+	}
+}
+
+func TestParseMeasuresUnits(t *testing.T) {
+	measures := generic.ParseMeasures("5 min 45 MHz 3000m 198ft/45m")
+	if len(measures) != 3 || measures[0].Unit != "M" || measures[0].Value != 3000 || measures[1].Unit != "FT" {
+		t.Errorf("unexpected measures %v", measures)
+	}
+	if _, err := generic.ParseMeasure("5 min"); err == nil {
+		t.Error("5 min is not a measure")
+	}
+}
+
+func TestLoadAirspaces(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	doc := pageDocument(t, fmt.Sprintf(mockAdminDataSection, "RJTT", "RJTT")+fmt.Sprintf(mockAirspacesSection, "RJTT", "RJTT"))
+	if err := apt.loadAdminData(doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := apt.loadAirspaces(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apt.Airspaces) != 2 {
+		t.Fatalf("expected 2 airspaces, got %+v", apt.Airspaces)
+	}
+	ctr := apt.Airspaces[0]
+	if ctr.Name != "Tokyo CTR" || ctr.Type != "CTR" || ctr.Class != "D" {
+		t.Errorf("unexpected CTR %+v", ctr)
+	}
+	if ctr.CallSign != "Tokyo Tower" || ctr.Language != "English, Japanese" || ctr.Remarks != "Nil" {
+		t.Errorf("unexpected CTR service %q %q %q", ctr.CallSign, ctr.Language, ctr.Remarks)
+	}
+	if ctr.Upper.Value != 3000 || ctr.Upper.Reference != "AMSL" || ctr.Lower.Reference != "SFC" {
+		t.Errorf("unexpected CTR vertical limits %v / %v", ctr.Upper, ctr.Lower)
+	}
+	if ctr.TransitionAltitude.Value != 14000 || ctr.TransitionAltitude.Unit != "FT" {
+		t.Errorf("unexpected transition altitude %v", ctr.TransitionAltitude)
+	}
+	//the circle is centred on the ARP
+	if len(ctr.Boundary) != 73 {
+		t.Fatalf("unexpected CTR boundary %v", ctr.Boundary)
+	}
+	assertNear(t, "CTR radius", apt.AdminData.ArpCoord.DistanceTo(ctr.Boundary[0]), 5)
+
+	atz := apt.Airspaces[1]
+	if atz.Name != "Haneda ATZ" || atz.Type != "ATZ" || atz.Class != "G" || len(atz.Boundary) != 5 {
+		t.Errorf("unexpected ATZ %+v", atz)
+	}
+	if atz.Upper.Reference != "AGL" || atz.CallSign != "" {
+		t.Errorf("unexpected ATZ %v %q", atz.Upper, atz.CallSign)
+	}
+}
+
+func TestLoadAirspacesWithoutArp(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	err := apt.loadAirspaces(pageDocument(t, fmt.Sprintf(mockAirspacesSection, "RJTT", "RJTT")))
+	if err == nil || !strings.Contains(err.Error(), "Tokyo CTR") || !strings.Contains(err.Error(), "ARP") {
+		t.Errorf("expected a Tokyo CTR error, got %v", err)
+	}
+	//the circle is not centred on 0°N 0°E, the polygon is kept
+	if len(apt.Airspaces) != 2 || len(apt.Airspaces[0].Boundary) != 0 || len(apt.Airspaces[1].Boundary) != 5 {
+		t.Errorf("unexpected airspaces %+v", apt.Airspaces)
+	}
+}
+
+func TestGetPDFFromHTMLClassifiesCharts(t *testing.T) {
+	apt := newPageAirport(t, "RJTT", fmt.Sprintf(mockChartsSection, "RJTT", "RJTT"))
+	apt.AipDocument = &JpAipDocument{}
+	if err := apt.GetPDFFromHTML(nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	//the text PDF, then the charts in the order of the list
+	if len(apt.PdfData) != 8 || apt.PdfData[0].DataContentType != "Text" {
+		t.Fatalf("unexpected PDF files %+v", apt.PdfData)
+	}
+	expected := []struct {
+		category, title, procedure string
+		runways                    []string
+	}{
+		{generic.ChartADC, "AERODROME CHART", "", nil},
+		{generic.ChartPDC, "PARKING CHART 1", "", nil},
+		{generic.ChartSID, "LAXAS ONE DEPARTURE RWY 34L/34R", "LAXAS ONE DEPARTURE", []string{"34L", "34R"}},
+		{generic.ChartIAC, "ILS Z RWY34R", "ILS Z RWY34R", []string{"34R"}},
+		{generic.ChartIAC, "RNAV (GNSS) Y RWY 22", "RNAV (GNSS) Y RWY 22", []string{"22"}},
+		{generic.ChartVAC, "VISUAL APPROACH CHART RWY16L", "VISUAL APPROACH CHART RWY16L", []string{"16L"}},
+		{generic.ChartOther, "NOISE ABATEMENT", "", nil},
+	}
+	for i, e := range expected {
+		p := apt.PdfData[i+1]
+		if p.Category != e.category || p.Title != e.title || p.Procedure != e.procedure ||
+			strings.Join(p.Runways, " ") != strings.Join(e.runways, " ") {
+			t.Errorf("%s: got %s %q %q %v, want %s %q %q %v", p.FileName, p.Category, p.Title, p.Procedure, p.Runways,
+				e.category, e.title, e.procedure, e.runways)
+		}
+	}
+}
+
+func TestGetAirportDataMissingSection(t *testing.T) {
+	apt := newPageAirport(t, "RJFF", fmt.Sprintf(mockComDataSection, "RJFF", "RJFF"))
+	err := apt.GetAirportData()
+	if err == nil || !strings.Contains(err.Error(), "AD 2.2:") {
+		t.Errorf("expected an error for the missing AD 2.2 section, got %v", err)
+	}
+	if len(apt.Com) != 3 {
+		t.Errorf("the AD 2.18 section shall be kept, got %+v", apt.Com)
+	}
+}
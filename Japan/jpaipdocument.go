@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 
@@ -14,83 +17,51 @@ import (
 type JpAipDocument struct {
 	generic.AipDocument
 	Airports          []JpAirport
+	airportsMu        sync.Mutex
 }
 
-func (aipdcs *JpAipDocument) GetNavaids(cl *http.Client) []generic.Navaid {
-	var indexUrl = aipdcs.FullURLDir + JapanAis.AipIndexPageName
-	fmt.Println("   Retrieve RadioNavigation  in " + indexUrl)
-	resp, err := cl.Get(indexUrl)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	defer resp.Body.Close()
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		fmt.Println("No url found for navaid extraction")
-		log.Fatal(err)
-	}
-
-	var navaidpage string
-	doc.Find(`div[id="ENR-4details"]`).Each(func(index int, divhtml *goquery.Selection) {
-		divhtml.Find(`div[class="H3"]`).Each(func(index int, ahtml *goquery.Selection) {
-			t, titleEx := ahtml.Find("a").Attr("title")
-			if titleEx {
-				if strings.Contains(t, "NAVIGATION AIDS") {
-					href, hrefEx := ahtml.Find("a").Attr("href")
-					if hrefEx {
-						navaidpage = href
-						fmt.Println("Page to the Radio Navigation" + href)
-					}
-				}
-			}
-		})
-	})
-
-	fmt.Println("Retrieve data from " + aipdcs.FullURLDir + navaidpage)
-	navaidresp, err := cl.Get(aipdcs.FullURLDir + navaidpage)
+// GetNavaids retrieves the ENR 4.1 navaids from the page indicated in the AIP index page.
+// Each table is checked against the navaids identified in it.
+func (aipdcs *JpAipDocument) GetNavaids(cl *http.Client) ([]generic.Navaid, error) {
+	fmt.Println("   Retrieve RadioNavigation")
+	navaidsdoc, err := aipdcs.getEnrPage(cl, "ENR-4details", "NAVIGATION AIDS")
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("navaid extraction: %s", err)
 	}
 
-	defer navaidresp.Body.Close()
-	navaidsdoc, err := goquery.NewDocumentFromReader(navaidresp.Body)
-	if err != nil {
-		fmt.Println("No url found for navaid extraction")
-		log.Fatal(err)
-	}
-
-	navaids, trCount := loadNavaidsFromHtmlDoc(navaidsdoc)
-	//confirm we have the same number
-	if trCount == len(navaids) {
-		return nil
-	} else {
-		log.Println("Number of rows in the table and identified Navaids differs")
-		return nil
+	navaids, problems := loadNavaidsFromHtmlDoc(navaidsdoc)
+	//the tables not consistent with the identified navaids are reported, the navaids are kept
+	for _, p := range problems {
+		aipdcs.AddError(fmt.Errorf("navaid extraction: %s", p))
 	}
+	aipdcs.Navaids = navaids
+	fmt.Printf("Number of identified navaids: %d \n", len(navaids))
+	return navaids, nil
 }
 
-func loadNavaidsFromHtmlDoc(navaidsdoc *goquery.Document) (map[string]generic.Navaid, int) {
-	//navs := //[]Navaid{}
+// loadNavaidsFromHtmlDoc retrieves the navaids of the ENR 4.1 page, sorted by key.
+// For each table, the number of NAV rows shall be the number of identified navaids:
+// a row which is not converted or a navaid which appears several times is reported as a problem.
+func loadNavaidsFromHtmlDoc(navaidsdoc *goquery.Document) ([]generic.Navaid, []error) {
 	var navs = make(map[string]generic.Navaid)
-	trCount := 0
-	navaidsdoc.Find(`table`).Each(func(index int, divhtml *goquery.Selection) {
+	var problems []error
+	navaidsdoc.Find(`table`).Each(func(tableIndex int, divhtml *goquery.Selection) {
 		tbody := divhtml.Find(`tbody`).First()
-		trCount = 0
+		trCount := 0
+		navCount := 0
 		tbody.Find("tr").Each(func(index int, tr *goquery.Selection) {
 			id, titleEx := tr.Attr("id")
 			if titleEx {
-				fmt.Println(id)
 				if strings.HasPrefix(id, "NAV-") {
+					trCount++
 					nav := generic.Navaid{}
 					nav.SetFromHtmlSelection(tr)
-					if nav.Key != "" {
+					if strings.TrimSpace(nav.Id) != "" {
 						if val, ok := navs[nav.Key]; ok {
 							log.Printf("%s appears several time", val.Key)
 						} else {
 							navs[nav.Key] = nav
-							trCount++
+							navCount++
 						}
 					} else {
 						log.Printf("%s is disregarded - not NAV data", id)
@@ -100,59 +71,87 @@ func loadNavaidsFromHtmlDoc(navaidsdoc *goquery.Document) (map[string]generic.Na
 				}
 			}
 		})
+		//confirm we have the same number
+		if trCount != navCount {
+			problems = append(problems, fmt.Errorf("table %d has %d rows and %d identified navaids",
+				tableIndex+1, trCount, navCount))
+		}
 	})
 
-	return navs, trCount
+	navaids := make([]generic.Navaid, 0, len(navs))
+	for _, n := range navs {
+		navaids = append(navaids, n)
+	}
+	sort.Slice(navaids, func(i, j int) bool { return navaids[i].Key < navaids[j].Key })
+	return navaids, problems
 }
 
-func (aipdcs *JpAipDocument) LoadAirports(cl *http.Client) {
+// LoadAirports retrieves the airports list (aerodromes of AD 2 and heliports of AD 3)
+// from the AIP index page and, for each airport, downloads its page and identifies its PDF files.
+// An error is returned only if the index page cannot be retrieved. The errors
+// related to an airport are recorded in the airport.
+func (aipdcs *JpAipDocument) LoadAirports(cl *http.Client) error {
 	var indexUrl = aipdcs.FullURLDir + JapanAis.AipIndexPageName
 
 	fmt.Println("   Retrieve Airports list from: " + indexUrl)
-	resp, err := cl.Get(indexUrl)
-	defer resp.Body.Close()
+	doc, err := getHtmlDocument(cl, indexUrl)
 	if err != nil {
-		fmt.Println("Problem while reading %s \n", indexUrl)
-		log.Fatal(err)
-	} else {
+		return fmt.Errorf("airports extraction: %s", err)
+	}
 
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		if err != nil {
-			fmt.Println("No url found for airports extraction")
-			log.Fatal(err)
-		} else {
-
-			var countWkr int
-			var wg sync.WaitGroup
-			doc.Find(`div[id="AD-2details"]`).Each(func(index int, divhtml *goquery.Selection) {
-				divhtml.Find(`div[class="H3"]`).Each(func(index int, h3html *goquery.Selection) {
-					countWkr = countWkr + 1
-					fmt.Println("Main: Starting worker", countWkr)
-					wg.Add(1)
-					go aipdcs.retrieveAirport(&wg, h3html, cl)
-				})
+	//the number of airports retrieved at the same time is limited by the PageWorkers
+	var countWkr int
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, generic.ConfData.PageWorkerCount())
+	for _, part := range []struct{ details, airportType string }{
+		{"AD-2details", generic.AerodromeType},
+		{"AD-3details", generic.HeliportType}} {
+		airportType := part.airportType
+		doc.Find(`div[id="` + part.details + `"]`).Each(func(index int, divhtml *goquery.Selection) {
+			divhtml.Find(`div[class="H3"]`).Each(func(index int, h3html *goquery.Selection) {
+				countWkr = countWkr + 1
+				fmt.Println("Main: Starting worker", countWkr)
+				wg.Add(1)
+				sem <- struct{}{}
+				go func(h3html *goquery.Selection) {
+					defer func() { <-sem }()
+					aipdcs.retrieveAirport(&wg, h3html, cl, airportType)
+				}(h3html)
 			})
-
-			fmt.Println("Main: Waiting for workers to finish")
-			wg.Wait()
-			fmt.Println("Main: Completed")
-		}
+		})
 	}
+
+	fmt.Println("Main: Waiting for workers to finish")
+	wg.Wait()
+	saveManifest(aipdcs)
+	fmt.Println("Main: Completed")
+	return nil
+}
+
+// airportTitleWords gives the word of the link title identifying each type of airport.
+var airportTitleWords = map[string]string{
+	generic.AerodromeType: "AERO",
+	generic.HeliportType:  "HELI",
 }
 
-func (aipDoc *JpAipDocument) retrieveAirport(wg *sync.WaitGroup, h3html *goquery.Selection, cl *http.Client) {
+// retrieveAirport creates the airport (aerodrome or heliport) of the index page entry,
+// downloads its page and identifies its PDF files.
+func (aipDoc *JpAipDocument) retrieveAirport(wg *sync.WaitGroup, h3html *goquery.Selection, cl *http.Client, airportType string) {
 	defer wg.Done()
 	h3html.Find("a").Each(func(index int, ahtml *goquery.Selection) {
 		idAd, exist := ahtml.Attr("title")
 		if exist {
-			if strings.Contains(idAd, "AERO") || strings.Contains(idAd, "aero") {
+			if strings.Contains(strings.ToUpper(idAd), airportTitleWords[airportType]) {
 				idId, idEx := ahtml.Attr("id")
-				if idEx {
+				if idEx && len(idId) >= 9 {
 					ad := JpAirport{}
 					ad.AipDocument = aipDoc
-					
+					ad.AirportType = airportType
 					ad.Icao = idId[5:9]
-					ad.Title = ahtml.Text()[7:]
+					ad.Title = ahtml.Text()
+					if len(ad.Title) > 7 {
+						ad.Title = ad.Title[7:]
+					}
 					href, hrefEx := ahtml.Attr("href")
 					if hrefEx {
 						ad.Link = href
@@ -160,10 +159,22 @@ func (aipDoc *JpAipDocument) retrieveAirport(wg *sync.WaitGroup, h3html *goquery
 					ad.PdfData = []generic.PdfData{}
 					fmt.Println(ad.Icao)
 					fmt.Println(ad.Title)
-					ad.DownloadPage(cl)
-					ad.GetPDFFromHTML(cl, aipDoc.FullURLDir)
+					if err := ad.DownloadPage(cl); err != nil {
+						ad.AddError(err)
+					} else {
+						if err := ad.GetPDFFromHTML(cl, aipDoc.FullURLDir); err != nil {
+							ad.AddError(err)
+						}
+						//the airport data are informative, a problem does not stop the downloads
+						if err := ad.GetAirportData(); err != nil {
+							log.Printf("Airport %s - %s \n", ad.Icao, err)
+						}
+					}
 					//maps, i := ad.GetNavaids()
+					//the airport is kept even in case of error, so it appears in the failure report
+					aipDoc.airportsMu.Lock()
 					aipDoc.Airports = append(aipDoc.Airports, ad)
+					aipDoc.airportsMu.Unlock()
 				}
 			}
 		}
@@ -176,31 +187,98 @@ func (aipDoc *JpAipDocument) DownloadAllAiportsHtmlPage(cl *http.Client) {
 		docsWg.Add(1)
 		apt := &aipDoc.Airports[i]
 		apt.AipDocument = aipDoc
-		generic.DownloadAirportPageSync(cl, &docsWg, apt)
+		if err := generic.DownloadAirportPageSync(cl, &docsWg, apt); err != nil {
+			apt.AddError(err)
+		}
 	}
 	docsWg.Wait()
 }
 
-func (aipDoc *JpAipDocument) DownloadAllAiportsData(client *http.Client) {
-	jobs := make(chan *generic.PdfData, 10)
+// DownloadAllAiportsData downloads the PDF files of all the airports.
+// If merge is set, the files of each airport are merged once downloaded.
+// The number of concurrent downloads is defined by the DownloadWorkers of the configuration.
+func (aipDoc *JpAipDocument) DownloadAllAiportsData(client *http.Client, merge bool) {
+	nbWorkers := generic.ConfData.DownloadWorkerCount()
+	jobs := make(chan *generic.PdfData, 2*nbWorkers)
+
+	//create the workers
+	for w := 1; w <= nbWorkers; w++ {
+		go worker(w, aipDoc.FullURLDir, client, jobs)
+	}
 
-	var w int
 	var docsWg sync.WaitGroup
 	for i, _ := range aipDoc.Airports {
 		docsWg.Add(1)
 
 		apt := &aipDoc.Airports[i]
 		apt.AipDocument = aipDoc //refresh the pointer (case we miss something)
-		//create the workers. the number is limited by 5 at the time being
-		if w < 5 {
-			w = w + 1
-			go worker(w, aipDoc.FullURLDir, client, jobs)
+		DownloadAndMergeAiportData(&apt.Airport, &jobs, &docsWg, false, merge)
+	}
+	docsWg.Wait()
+	close(jobs)
+
+	if merge {
+		fmt.Println("Download and merge - done")
+	} else {
+		fmt.Println("Download - done")
+	}
+}
+
+// MergeAllAiportsData merges the PDF files already downloaded for each airport.
+// Nothing is downloaded: an airport with missing files is reported and skipped.
+func (aipDoc *JpAipDocument) MergeAllAiportsData() {
+	for i := range aipDoc.Airports {
+		apt := &aipDoc.Airports[i]
+		apt.AipDocument = aipDoc
+		if missing := missingPdfData(&apt.Airport); len(missing) > 0 {
+			apt.AddError(fmt.Errorf("%d file(s) not downloaded, no merge done", len(missing)))
+			continue
+		}
+		if err := MergeAiportData(&apt.Airport); err != nil {
+			apt.AddError(fmt.Errorf("merge failed: %s", err))
 		}
+	}
+	fmt.Println("Merge - done")
+}
 
-		DownloadAndMergeAiportData(&apt.Airport, &jobs, &docsWg, false)
+// VerifyAllAiportsData confirms that the PDF files of each airport are on disk and are
+// PDF files, and that the merged files exist. It returns the list of the problems found.
+func (aipDoc *JpAipDocument) VerifyAllAiportsData() []error {
+	var errs []error
+	for i := range aipDoc.Airports {
+		apt := &aipDoc.Airports[i]
+		apt.AipDocument = aipDoc
+		errs = append(errs, missingPdfData(&apt.Airport)...)
+		for _, m := range expectedMergedData(&apt.Airport) {
+			if _, err := os.Stat(filepath.Join(m.FileDirectory, m.FileName)); err != nil {
+				errs = append(errs, fmt.Errorf("%s: merged file %s", apt.Icao, err))
+			}
+		}
 	}
-	docsWg.Wait()
+	return errs
+}
 
-	fmt.Println("Download and merge - done")
+// AirportsList provides the airports of the document in their generic form.
+func (aipDoc *JpAipDocument) AirportsList() []*generic.Airport {
+	apts := make([]*generic.Airport, len(aipDoc.Airports))
+	for i := range aipDoc.Airports {
+		apts[i] = &aipDoc.Airports[i].Airport
+	}
+	return apts
+}
+
+// getHtmlDocument retrieves and parses the HTML page at the indicated url.
+// A response status outside of the 2xx range is an error, temporary errors are tried again.
+func getHtmlDocument(cl *http.Client, url string) (*goquery.Document, error) {
+	resp, err := generic.GetWithRetry(cl, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
 
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", url, err)
+	}
+	return doc, nil
 }
@@ -0,0 +1,93 @@
+package japan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NagoDede/aipdownloader/generic"
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestLoadNavaidsFromHtmlDoc(t *testing.T) {
+	page := `<html><body>
+<table><tbody>
+<tr id="NAV-1"><td>HAKODATE<p>VOR/DME</p></td><td>HWE</td><td>112.1MHz</td><td>H24</td><td><p>414611.00N</p><p>1404920.00E</p></td><td>100FT</td><td>Nil</td></tr>
+<tr id="NAV-2"><td>AOMORI<p>VOR/DME</p></td><td>MIE</td><td>116.0MHz</td><td>H24</td><td><p>404406.00N</p><p>1404125.00E</p></td><td>650FT</td><td>Nil</td></tr>
+</tbody></table>
+<table><tbody>
+<tr id="NAV-3"><td>HAKODATE<p>VOR/DME</p></td><td>HWE</td><td>112.1MHz</td><td>H24</td><td><p>414611.00N</p><p>1404920.00E</p></td><td>100FT</td><td>Nil</td></tr>
+<tr id="NAV-4"><td>KUSHIRO<p>NDB</p></td><td>KS</td><td>353kHz</td><td>H24</td><td><p>430241.00N</p><p>1442324.00E</p></td><td>-</td><td>Nil</td></tr>
+</tbody></table>
+</body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	navaids, problems := loadNavaidsFromHtmlDoc(doc)
+	if len(navaids) != 3 {
+		t.Fatalf("expected 3 navaids, got %+v", navaids)
+	}
+	if navaids[0].Key != "HWE VOR/DME" || navaids[1].Key != "KS NDB" || navaids[2].Key != "MIE VOR/DME" {
+		t.Errorf("navaids not sorted by key: %s, %s, %s", navaids[0].Key, navaids[1].Key, navaids[2].Key)
+	}
+	//the duplicated HWE makes the second table inconsistent
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "table 2 has 2 rows and 1 identified navaids") {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
+
+func TestLoadWaypointsFromHtmlDoc(t *testing.T) {
+	page := strings.Replace(mockWaypointsPage, "</tbody>", "<tr><td>BADPT</td><td>Nil</td><td>A1</td><td>Nil</td></tr>\n"+
+		"<tr><td>SHORT</td><td>5N 1394652E</td><td>A1</td><td>Nil</td></tr>\n</tbody>", 1)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waypoints, problems := loadWaypointsFromHtmlDoc(doc)
+	if len(waypoints) != 2 || waypoints[0].Id != "ABSAK" || waypoints[1].Id != "ADDUM" {
+		t.Fatalf("unexpected waypoints %+v", waypoints)
+	}
+	addum := waypoints[1]
+	if strings.Join(addum.Routes, " ") != "Y11 Y111 V17" {
+		t.Errorf("unexpected ADDUM routes %v", addum.Routes)
+	}
+	assertNear(t, "ADDUM latitude", addum.Position.Latitude, 34+38.0/60+52.0/3600)
+	assertNear(t, "ADDUM longitude", addum.Position.Longitude, 139+54.0/60+7.0/3600)
+	if waypoints[0].Remarks != "FIR boundary" {
+		t.Errorf("unexpected ABSAK remarks %q", waypoints[0].Remarks)
+	}
+	//the points without valid coordinates are reported
+	if len(problems) != 2 || !strings.Contains(problems[0].Error(), "BADPT") || !strings.Contains(problems[1].Error(), "SHORT") {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
+
+func TestLoadRoutesFromHtmlDoc(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(mockRoutesPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := loadRoutesFromHtmlDoc(doc)
+	if len(routes) != 2 || routes[0].Designator != "A1" || routes[1].Designator != "Y11" {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+	a1 := routes[0]
+	if len(a1.Segments) != 2 || a1.Segments[0].From != "ABSAK" || a1.Segments[0].To != "HWE" ||
+		a1.Segments[1].From != "HWE" || a1.Segments[1].To != "ADDUM" {
+		t.Fatalf("unexpected A1 segments %+v", a1.Segments)
+	}
+	seg := a1.Segments[0]
+	if seg.Track != 204 || seg.Distance != 25.3 || seg.Direction != generic.BothDirections {
+		t.Errorf("unexpected A1 segment %+v", seg)
+	}
+	if seg.Upper.String() != "FL600" || seg.Lower.String() != "7000 FT AMSL" || seg.Lower.FlightLevel() != 70 {
+		t.Errorf("unexpected A1 limits %s / %s", seg.Upper, seg.Lower)
+	}
+	if y11 := routes[1].Segments; len(y11) != 1 || y11[0].Direction != generic.ForwardOnly {
+		t.Errorf("unexpected Y11 segments %+v", y11)
+	}
+}
+
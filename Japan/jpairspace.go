@@ -0,0 +1,226 @@
+package japan
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+// GetAirspaces retrieves the ENR 2.1 airspaces (FIR, UTA, CTA and TMA) from the page indicated in the AIP index page.
+// The airspaces whose limits cannot be converted are kept.
+func (aipdcs *JpAipDocument) GetAirspaces(cl *http.Client) ([]generic.Airspace, error) {
+	fmt.Println("   Retrieve Airspaces")
+	doc, err := aipdcs.getEnrPage(cl, "ENR-2details", "ENR 2.1")
+	if err != nil {
+		return nil, fmt.Errorf("airspace extraction: %s", err)
+	}
+
+	airspaces, problems := loadAirspacesFromHtmlDoc(doc)
+	for _, p := range problems {
+		aipdcs.AddError(fmt.Errorf("airspace extraction: %s", p))
+	}
+	aipdcs.Airspaces = airspaces
+	fmt.Printf("Number of identified airspaces: %d \n", len(airspaces))
+	return airspaces, nil
+}
+
+var (
+	airspaceTypeRe  = regexp.MustCompile(`\b(FIR|UIR|UTA|CTA|TMA|CTR|ATZ)\b`)
+	airspaceClassRe = regexp.MustCompile(`(?i)\bCLASS\s*:?\s*([A-G])\b`)
+	lateralLimitRe  = regexp.MustCompile(`(?i)[0-9]{6}(?:\.[0-9]+)?[NS]|CIRCLE|\bARC\b|\bALONG\b`)
+)
+
+// loadAirspacesFromHtmlDoc retrieves the airspaces of the ENR 2.1 page.
+// The columns are: name, lateral limits, vertical limits and class (one line each, the lateral limits
+// on several lines), unit providing the service, callsign, frequencies and remarks.
+// The rows whose name does not indicate an airspace type are not airspaces (titles, column numbers).
+func loadAirspacesFromHtmlDoc(doc *goquery.Document) ([]generic.Airspace, []error) {
+	var airspaces []generic.Airspace
+	var problems []error
+	doc.Find("tbody tr").Each(func(index int, tr *goquery.Selection) {
+		tds := tr.Find("td")
+		if tds.Length() < 4 {
+			return
+		}
+		lines := cellLines(tds.First())
+		if len(lines) == 0 {
+			return
+		}
+		asp, err := getAirspaceFromLinesOfjpAirspaceData(lines)
+		if asp.Type == "" {
+			return
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %s", asp.Name, err))
+		}
+
+		asp.Unit = cellText(tds.Eq(1))
+		asp.CallSign = cellText(tds.Eq(2))
+		asp.Frequencies = generic.ParseFrequencies(cellText(tds.Eq(3)))
+		if tds.Length() > 4 {
+			asp.Remarks = cellText(tds.Last())
+		}
+		airspaces = append(airspaces, asp)
+	})
+	return airspaces, problems
+}
+
+// cellLines provides the lines (p) of a table cell, or the text of the cell if it has no line.
+func cellLines(td *goquery.Selection) []string {
+	var lines []string
+	td.Find("p").Each(func(i int, p *goquery.Selection) {
+		if l := cellText(p); l != "" {
+			lines = append(lines, l)
+		}
+	})
+	if len(lines) == 0 {
+		if l := cellText(td); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// getAirspaceFromLinesOfjpAirspaceData converts the lines of the first column of an airspace row:
+// the name, then the lateral limits, the vertical limits and the class in any order.
+// The airspace type is given by the name. The airspace is provided even if its limits
+// cannot be converted.
+func getAirspaceFromLinesOfjpAirspaceData(lines []string) (generic.Airspace, error) {
+	asp := generic.Airspace{Name: lines[0]}
+	if m := airspaceTypeRe.FindString(strings.ToUpper(asp.Name)); m != "" {
+		asp.Type = m
+	}
+
+	var lateral []string
+	var limits []generic.VerticalLimit
+	for _, l := range lines[1:] {
+		switch {
+		case airspaceClassRe.MatchString(l):
+			asp.Class = airspaceClassRe.FindStringSubmatch(l)[1]
+		case lateralLimitRe.MatchString(l):
+			lateral = append(lateral, l)
+		default:
+			limits = append(limits, generic.ParseVerticalLimits(l)...)
+		}
+	}
+
+	if len(limits) > 0 {
+		asp.Upper = limits[0]
+	}
+	if len(limits) > 1 {
+		asp.Lower = limits[1]
+	}
+	if len(lateral) == 0 {
+		return asp, fmt.Errorf("no lateral limits")
+	}
+	boundary, err := generic.ParseLateralLimits(strings.Join(lateral, " "))
+	if err != nil {
+		return asp, err
+	}
+	asp.Boundary = boundary
+	if len(limits) < 2 {
+		return asp, fmt.Errorf("incomplete vertical limits")
+	}
+	return asp, nil
+}
+
+// GetRestrictedAreas retrieves the ENR 5.1 prohibited, restricted and danger areas from the page
+// indicated in the AIP index page.
+// The areas whose limits cannot be converted are kept.
+func (aipdcs *JpAipDocument) GetRestrictedAreas(cl *http.Client) ([]generic.RestrictedArea, error) {
+	fmt.Println("   Retrieve Prohibited, Restricted and Danger Areas")
+	doc, err := aipdcs.getEnrPage(cl, "ENR-5details", "ENR 5.1")
+	if err != nil {
+		return nil, fmt.Errorf("restricted area extraction: %s", err)
+	}
+
+	areas, problems := loadRestrictedAreasFromHtmlDoc(doc)
+	for _, p := range problems {
+		aipdcs.AddError(fmt.Errorf("restricted area extraction: %s", p))
+	}
+	aipdcs.RestrictedAreas = areas
+	fmt.Printf("Number of identified restricted areas: %d \n", len(areas))
+	return areas, nil
+}
+
+var (
+	restrictedAreaIdRe = regexp.MustCompile(`^([A-Z]{2})([PRD])\s?([0-9]+[A-Z]?)\b`)
+	activityRe         = regexp.MustCompile(`(?i)^(?:time of activity|activity|active)\s*:?\s*(.*)$`)
+	activityHoursRe    = regexp.MustCompile(`\bH24\b|\bHJ\b|\bHN\b|\bHX\b|\b[0-9]{4}\s*-\s*[0-9]{4}\b|NOTAM`)
+)
+
+// loadRestrictedAreasFromHtmlDoc retrieves the areas of the ENR 5.1 page. The columns are:
+//  - identification, name and lateral limits (one line each, the lateral limits on several lines),
+//  - upper and lower limits,
+//  - remarks, including the time of activity.
+// The rows which do not start with an area identifier (ex: RJP1, RJR102) are not areas.
+func loadRestrictedAreasFromHtmlDoc(doc *goquery.Document) ([]generic.RestrictedArea, []error) {
+	var areas []generic.RestrictedArea
+	var problems []error
+	doc.Find("tbody tr").Each(func(index int, tr *goquery.Selection) {
+		tds := tr.Find("td")
+		if tds.Length() < 2 {
+			return
+		}
+		lines := cellLines(tds.First())
+		if len(lines) == 0 {
+			return
+		}
+		m := restrictedAreaIdRe.FindStringSubmatch(lines[0])
+		if m == nil {
+			return
+		}
+
+		area := generic.RestrictedArea{Id: m[1] + m[2] + m[3], Type: m[2]}
+		//the name is on the identifier line or on the following one
+		area.Name = strings.TrimSpace(strings.TrimLeft(lines[0][len(m[0]):], " -"))
+		lateral := lines[1:]
+		if area.Name == "" && len(lateral) > 0 && !lateralLimitRe.MatchString(lateral[0]) {
+			area.Name, lateral = lateral[0], lateral[1:]
+		}
+
+		var err error
+		if len(lateral) == 1 && strings.Contains(strings.ToUpper(lateral[0]), "CIRCLE") {
+			var centre generic.GeoPosition
+			if centre, area.Radius, err = generic.ParseCircle(lateral[0]); err == nil {
+				area.Centre = &centre
+				area.Boundary = generic.Circle(centre, area.Radius)
+			}
+		} else {
+			area.Boundary, err = generic.ParseLateralLimits(strings.Join(lateral, " "))
+		}
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %s", area.Id, err))
+		}
+
+		limits := generic.ParseVerticalLimits(cellText(tds.Eq(1)))
+		if len(limits) > 0 {
+			area.Upper = limits[0]
+		}
+		if len(limits) > 1 {
+			area.Lower = limits[1]
+		} else {
+			problems = append(problems, fmt.Errorf("%s: incomplete vertical limits", area.Id))
+		}
+
+		if tds.Length() > 2 {
+			var remarks []string
+			for _, l := range cellLines(tds.Eq(2)) {
+				if a := activityRe.FindStringSubmatch(l); a != nil {
+					area.ActivityHours = a[1]
+				} else if area.ActivityHours == "" && activityHoursRe.MatchString(l) {
+					area.ActivityHours = l
+				} else {
+					remarks = append(remarks, l)
+				}
+			}
+			area.Remarks = strings.Join(remarks, " ")
+		}
+		areas = append(areas, area)
+	})
+	return areas, problems
+}
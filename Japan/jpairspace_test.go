@@ -0,0 +1,116 @@
+package japan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/NagoDede/aipdownloader/generic"
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestLoadAirspacesFromHtmlDoc(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(mockAirspacesPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	airspaces, problems := loadAirspacesFromHtmlDoc(doc)
+	if len(problems) != 0 {
+		t.Fatal(problems)
+	}
+	if len(airspaces) != 3 {
+		t.Fatalf("expected 3 airspaces, got %+v", airspaces)
+	}
+
+	fir := airspaces[0]
+	if fir.Name != "FUKUOKA FIR" || fir.Type != "FIR" || fir.Upper.String() != "UNL" || fir.Lower.String() != "SFC" {
+		t.Errorf("unexpected FIR %s %s %s - %s", fir.Name, fir.Type, fir.Upper, fir.Lower)
+	}
+	//the segment along the boundary is a straight line, the polygon is closed
+	if len(fir.Boundary) != 5 || fir.Boundary[0] != fir.Boundary[4] {
+		t.Errorf("unexpected FIR boundary %v", fir.Boundary)
+	}
+	if fir.Unit != "Tokyo ACC" || fir.CallSign != "Tokyo Control" || len(fir.Frequencies) != 2 {
+		t.Errorf("unexpected FIR unit %s %s %v", fir.Unit, fir.CallSign, fir.Frequencies)
+	}
+
+	tma := airspaces[1]
+	if tma.Type != "TMA" || tma.Class != "C" || tma.Upper.String() != "FL140" || tma.Lower.FlightLevel() != 30 {
+		t.Errorf("unexpected TMA %s class %s %s - %s", tma.Type, tma.Class, tma.Upper, tma.Lower)
+	}
+	//the arc points are at 20 NM of the centre
+	centre := generic.GeoPosition{Latitude: 35 + 33.0/60 + 12.0/3600, Longitude: 139 + 46.0/60 + 52.0/3600}
+	if len(tma.Boundary) <= 6 {
+		t.Fatalf("the arc is not approximated: %v", tma.Boundary)
+	}
+	if d := centre.DistanceTo(tma.Boundary[2]); d < 19.9 || d > 20.1 {
+		t.Errorf("arc point at %f NM of the centre", d)
+	}
+
+	ctr := airspaces[2]
+	if ctr.Type != "CTR" || ctr.Class != "D" || len(ctr.Boundary) != 73 {
+		t.Errorf("unexpected CTR %s class %s, %d points", ctr.Type, ctr.Class, len(ctr.Boundary))
+	}
+	centre = generic.GeoPosition{Latitude: 34 + 38.0/60, Longitude: 135 + 14.0/60}
+	for _, p := range ctr.Boundary {
+		if d := centre.DistanceTo(p); d < 4.99 || d > 5.01 {
+			t.Errorf("circle point at %f NM of the centre", d)
+			break
+		}
+	}
+}
+
+func TestLoadAirspacesReportsInvalidLimits(t *testing.T) {
+	page := `<table><tbody><tr><td><p>SENDAI TMA</p><p>382000N 1405000E - 383000N 1410000E</p><p>FL140</p></td>
+<td>Sendai Approach</td><td>Sendai Approach</td><td>120.2MHz</td></tr></tbody></table>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	airspaces, problems := loadAirspacesFromHtmlDoc(doc)
+	if len(airspaces) != 1 || airspaces[0].Unit != "Sendai Approach" {
+		t.Errorf("the airspace shall be kept: %+v", airspaces)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0].Error(), "SENDAI TMA") {
+		t.Errorf("unexpected problems %v", problems)
+	}
+}
+
+func TestLoadRestrictedAreasFromHtmlDoc(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(mockRestrictedAreasPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	areas, problems := loadRestrictedAreasFromHtmlDoc(doc)
+	if len(problems) != 0 {
+		t.Fatal(problems)
+	}
+	if len(areas) != 2 {
+		t.Fatalf("expected 2 areas, got %+v", areas)
+	}
+
+	p1 := areas[0]
+	if p1.Id != "RJP1" || p1.Type != "P" || p1.Name != "IMPERIAL PALACE" || p1.ActivityHours != "H24" ||
+		p1.Remarks != "Flight prohibited" {
+		t.Errorf("unexpected P1 %+v", p1)
+	}
+	if p1.Centre == nil || p1.Radius != 0.5 || len(p1.Boundary) != 73 {
+		t.Errorf("unexpected P1 circle %v %f, %d points", p1.Centre, p1.Radius, len(p1.Boundary))
+	}
+	if p1.Upper.String() != "5000 FT AMSL" || p1.Lower.String() != "SFC" {
+		t.Errorf("unexpected P1 limits %s - %s", p1.Upper, p1.Lower)
+	}
+
+	r102 := areas[1]
+	if r102.Id != "RJR102" || r102.Type != "R" || r102.Name != "SHIMOFUSA" || r102.Centre != nil {
+		t.Errorf("unexpected R102 %+v", r102)
+	}
+	if len(r102.Boundary) != 5 || r102.Upper.String() != "FL230" || r102.Lower.FlightLevel() != 30 {
+		t.Errorf("unexpected R102 geometry %v %s - %s", r102.Boundary, r102.Upper, r102.Lower)
+	}
+	if r102.ActivityHours != "MON-FRI 0000-0900 UTC" || r102.Remarks != "Military training" {
+		t.Errorf("unexpected R102 activity %q remarks %q", r102.ActivityHours, r102.Remarks)
+	}
+}
@@ -0,0 +1,282 @@
+package japan
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+// findEnrPages provides the en-route pages whose title contains the indicated text.
+// The pages are searched in the indicated part (ex: ENR-4details) of the AIP index page.
+func (aipdcs *JpAipDocument) findEnrPages(cl *http.Client, details string, title string) ([]string, error) {
+	var indexUrl = aipdcs.FullURLDir + JapanAis.AipIndexPageName
+	doc, err := getHtmlDocument(cl, indexUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	var pages []string
+	doc.Find(fmt.Sprintf(`div[id="%s"]`, details)).Each(func(index int, divhtml *goquery.Selection) {
+		divhtml.Find(`div[class="H3"]`).Each(func(index int, ahtml *goquery.Selection) {
+			t, titleEx := ahtml.Find("a").Attr("title")
+			if titleEx && strings.Contains(t, title) {
+				href, hrefEx := ahtml.Find("a").Attr("href")
+				if hrefEx {
+					pages = append(pages, href)
+				}
+			}
+		})
+	})
+
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no %s page in %s", title, indexUrl)
+	}
+	return pages, nil
+}
+
+// getEnrPage retrieves the en-route page whose title contains the indicated text.
+// The page is searched in the indicated part (ex: ENR-4details) of the AIP index page.
+func (aipdcs *JpAipDocument) getEnrPage(cl *http.Client, details string, title string) (*goquery.Document, error) {
+	pages, err := aipdcs.findEnrPages(cl, details, title)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("Retrieve data from " + aipdcs.FullURLDir + pages[0])
+	return getHtmlDocument(cl, aipdcs.FullURLDir+pages[0])
+}
+
+// cellText provides the text of a table cell on a single line.
+// The lines of the cell (br, p) are separated by a space.
+func cellText(td *goquery.Selection) string {
+	td.Find("br").ReplaceWithHtml(" ")
+	td.Find("p").AppendHtml(" ")
+	return strings.Join(strings.Fields(td.Text()), " ")
+}
+
+// GetWaypoints retrieves the ENR 4.4 significant points from the page indicated in the AIP index page.
+func (aipdcs *JpAipDocument) GetWaypoints(cl *http.Client) ([]generic.Waypoint, error) {
+	fmt.Println("   Retrieve Significant Points")
+	doc, err := aipdcs.getEnrPage(cl, "ENR-4details", "SIGNIFICANT POINTS")
+	if err != nil {
+		return nil, fmt.Errorf("waypoint extraction: %s", err)
+	}
+
+	waypoints, problems := loadWaypointsFromHtmlDoc(doc)
+	for _, p := range problems {
+		aipdcs.AddError(fmt.Errorf("waypoint extraction: %s", p))
+	}
+	aipdcs.Waypoints = waypoints
+	fmt.Printf("Number of identified waypoints: %d \n", len(waypoints))
+	return waypoints, nil
+}
+
+var (
+	routeDesignatorRe = regexp.MustCompile(`\b[A-Z]{1,2}[0-9]{1,4}[A-Z]?\b`)
+	//Exemple: 343852N 1395407E
+	coordinatesPairRe = regexp.MustCompile(`[0-9]{6}(?:\.[0-9]+)?[NS]\s*[0-9]{7}(?:\.[0-9]+)?[EW]`)
+)
+
+// loadWaypointsFromHtmlDoc retrieves the waypoints of the ENR 4.4 page, sorted by identifier.
+// The columns are: name-code designator, coordinates, ATS routes and remarks.
+// The rows whose coordinates cannot be converted are reported as problems.
+func loadWaypointsFromHtmlDoc(doc *goquery.Document) ([]generic.Waypoint, []error) {
+	idre := regexp.MustCompile(`^[A-Z]{5}$`)
+
+	var wpts = make(map[string]generic.Waypoint)
+	var problems []error
+	doc.Find("tbody tr").Each(func(index int, tr *goquery.Selection) {
+		var cells []string
+		tr.Find("td").Each(func(i int, td *goquery.Selection) {
+			cells = append(cells, cellText(td))
+		})
+		if len(cells) < 3 || !idre.MatchString(cells[0]) {
+			return
+		}
+
+		wpt := generic.Waypoint{Id: cells[0]}
+		pos, err := generic.ParseGeoPosition(cells[1])
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s coordinates: %s", wpt.Id, err))
+			return
+		}
+		wpt.Position = pos
+		wpt.Routes = routeDesignatorRe.FindAllString(cells[2], -1)
+		if len(cells) > 3 {
+			wpt.Remarks = cells[len(cells)-1]
+		}
+
+		if _, ok := wpts[wpt.Id]; ok {
+			log.Printf("%s appears several time", wpt.Id)
+			return
+		}
+		wpts[wpt.Id] = wpt
+	})
+
+	waypoints := make([]generic.Waypoint, 0, len(wpts))
+	for _, w := range wpts {
+		waypoints = append(waypoints, w)
+	}
+	sort.Slice(waypoints, func(i, j int) bool { return waypoints[i].Id < waypoints[j].Id })
+	return waypoints, problems
+}
+
+// GetRoutes retrieves the ATS routes of the ENR 3 pages (ENR 3.1 to ENR 3.5) indicated in the AIP index page.
+// The points of the routes are resolved against the navaids and the waypoints, which are retrieved
+// if not already known. The pages which cannot be retrieved and the unknown points are reported.
+func (aipdcs *JpAipDocument) GetRoutes(cl *http.Client) ([]generic.Route, error) {
+	if aipdcs.Navaids == nil {
+		if _, err := aipdcs.GetNavaids(cl); err != nil {
+			aipdcs.AddError(err)
+		}
+	}
+	if aipdcs.Waypoints == nil {
+		if _, err := aipdcs.GetWaypoints(cl); err != nil {
+			aipdcs.AddError(err)
+		}
+	}
+
+	fmt.Println("   Retrieve ATS Routes")
+	pages, err := aipdcs.findEnrPages(cl, "ENR-3details", "ROUTES")
+	if err != nil {
+		return nil, fmt.Errorf("route extraction: %s", err)
+	}
+
+	var routes []generic.Route
+	for _, page := range pages {
+		fmt.Println("Retrieve data from " + aipdcs.FullURLDir + page)
+		doc, err := getHtmlDocument(cl, aipdcs.FullURLDir+page)
+		if err != nil {
+			aipdcs.AddError(fmt.Errorf("route extraction: %s", err))
+			continue
+		}
+		routes = append(routes, loadRoutesFromHtmlDoc(doc)...)
+	}
+	routes = mergeRoutes(routes)
+	aipdcs.Routes = routes
+
+	_, problems := generic.RouteGraphOf(aipdcs)
+	for _, p := range problems {
+		aipdcs.AddError(fmt.Errorf("route extraction: %s", p))
+	}
+	fmt.Printf("Number of identified routes: %d \n", len(routes))
+	return routes, nil
+}
+
+// mergeRoutes gathers the segments of the routes published several times (ex: on several pages).
+// The routes are sorted by designator.
+func mergeRoutes(routes []generic.Route) []generic.Route {
+	var merged []generic.Route
+	index := make(map[string]int)
+	for _, r := range routes {
+		if i, ok := index[r.Designator]; ok {
+			merged[i].Segments = append(merged[i].Segments, r.Segments...)
+			continue
+		}
+		index[r.Designator] = len(merged)
+		merged = append(merged, r)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Designator < merged[j].Designator })
+	return merged
+}
+
+var routeTitleRe = regexp.MustCompile(`^[A-Z]{1,2}[0-9]{1,4}[A-Z]?\b`)
+
+// loadRoutesFromHtmlDoc retrieves the routes of an ENR 3 page. The rows of a route table are:
+//  - the route designator, alone in its row (ex: A1 or Y11 (RNAV 5)),
+//  - the significant points: identifier (in parentheses for a navaid) and coordinates,
+//  - between two points, the segment: track, distance, upper and lower limits, direction and remarks.
+// The direction cell holds arrows: ↓ only is a one way route in the published order,
+// ↑ only is a one way route in the opposite order. A "ONE WAY" remark without arrow
+// is considered in the published order.
+func loadRoutesFromHtmlDoc(doc *goquery.Document) []generic.Route {
+	var routes []generic.Route
+	var route *generic.Route
+	var lastPoint string
+	var segment *generic.RouteSegment
+	doc.Find("tr").Each(func(index int, tr *goquery.Selection) {
+		var cells []string
+		tr.Find("td").Each(func(i int, td *goquery.Selection) {
+			cells = append(cells, cellText(td))
+		})
+
+		switch {
+		case len(cells) == 1 && routeTitleRe.MatchString(cells[0]):
+			routes = append(routes, generic.Route{Designator: routeTitleRe.FindString(cells[0])})
+			route = &routes[len(routes)-1]
+			lastPoint, segment = "", nil
+		case route == nil || len(cells) == 0:
+			return
+		case coordinatesPairRe.MatchString(cells[0]):
+			id := getPointIdFromTextOfjpRouteData(cells[0])
+			if id == "" {
+				return
+			}
+			if segment != nil && lastPoint != "" {
+				segment.From, segment.To = lastPoint, id
+				route.Segments = append(route.Segments, *segment)
+			}
+			lastPoint, segment = id, nil
+		case len(cells) >= 4 && lastPoint != "":
+			segment = getRouteSegmentFromCellsOfjpRouteData(cells)
+		}
+	})
+	return routes
+}
+
+// getPointIdFromTextOfjpRouteData extracts the identifier of a significant point:
+// the navaid identifier in parentheses (ex: HAKODATE VOR/DME (HWE)), else the first code (ex: ADDUM).
+func getPointIdFromTextOfjpRouteData(t string) string {
+	navre := regexp.MustCompile(`\(([A-Z]{2,3})\)`)
+	if m := navre.FindStringSubmatch(t); m != nil {
+		return m[1]
+	}
+	codere := regexp.MustCompile(`^[A-Z]{2,5}$`)
+	for _, f := range strings.Fields(t) {
+		if codere.MatchString(f) {
+			return f
+		}
+	}
+	return ""
+}
+
+func getRouteSegmentFromCellsOfjpRouteData(cells []string) *generic.RouteSegment {
+	seg := &generic.RouteSegment{}
+	//Exemple: 123°/303°
+	if v, err := strconv.ParseFloat(numberRe.FindString(cells[0]), 32); err == nil {
+		seg.Track = float32(v)
+	}
+	//Exemple: 82.5
+	if v, err := strconv.ParseFloat(numberRe.FindString(cells[1]), 32); err == nil {
+		seg.Distance = float32(v)
+	}
+	//Exemple: FL600 7000 FT ALT
+	limits := generic.ParseVerticalLimits(cells[2])
+	if len(limits) > 0 {
+		seg.Upper = limits[0]
+	}
+	if len(limits) > 1 {
+		seg.Lower = limits[1]
+	}
+	down := strings.Contains(cells[3], "↓")
+	up := strings.Contains(cells[3], "↑")
+	if len(cells) > 4 {
+		seg.Remarks = strings.Join(cells[4:], " ")
+	}
+	switch {
+	case down && !up:
+		seg.Direction = generic.ForwardOnly
+	case up && !down:
+		seg.Direction = generic.BackwardOnly
+	case !up && !down && strings.Contains(strings.ToUpper(seg.Remarks), "ONE WAY"):
+		seg.Direction = generic.ForwardOnly
+	}
+	return seg
+}
@@ -12,11 +12,17 @@ import (
 	"strings"
 	"time"
 
+	"github.com/NagoDede/aipdownloader/generic"
 	"golang.org/x/net/publicsuffix"
 )
 
 var JapanAis JpData
 
+// The Japan provider is available in the country registry under the name "japan".
+func init() {
+	generic.RegisterCountry("japan", &JapanAis)
+}
+
 type JpLoginFormData struct {
 	FormName string `json:"formName"`
 	Password string `json:"password"`
@@ -43,27 +49,31 @@ directly set in the Json file.
 When the environement variable is used, the password definition shall respect
 the syntax "Env: ENV_VARIABLE_NAME". The function will then retrieve the content
 of the environment variable ENV_VARIABLE_NAME.
-If the environment variable does not exist or is empty, it generates a panic.
+If the environment variable does not exist or is empty, an error is returned,
+except in replay mode where no login is performed on the real website.
 To define an empty password, just set Password = ""  in the Json file.
 The same beahavior is extended to the User ID.
 
 */
-func (jpd *JpData) LoadJsonFile(path string) {
+func (jpd *JpData) LoadJsonFile(path string) error {
 	// Open our jsonFile
 	jsonFile, err := os.Open(path)
 	// if we os.Open returns an error then handle it
 	if err != nil {
-		fmt.Println(err)
+		return err
 	}
 
 	// defer the closing of our jsonFile so that we can parse it later on
 	defer jsonFile.Close()
 
 	byteValue, err := ioutil.ReadAll(jsonFile)
+	if err != nil {
+		return err
+	}
 
 	err = json.Unmarshal(byteValue, jpd)
 	if err != nil {
-		fmt.Println("error:", err)
+		return fmt.Errorf("%s: %s", path, err)
 	}
 
 	//The password may be provided by an environment variable
@@ -72,8 +82,8 @@ func (jpd *JpData) LoadJsonFile(path string) {
 		s = strings.TrimSpace(s)
 		jpd.LoginData.Password = os.Getenv(s)
 
-		if jpd.LoginData.Password == "" {
-			panic(fmt.Sprintf("Password Environment variable: %s  not defined\n", s))
+		if jpd.LoginData.Password == "" && generic.ConfData.HttpMode != generic.HttpModeReplay {
+			return fmt.Errorf("Password Environment variable: %s not defined", s)
 		}
 	}
 
@@ -83,59 +93,142 @@ func (jpd *JpData) LoadJsonFile(path string) {
 		s = strings.TrimSpace(s)
 		jpd.LoginData.UserID = os.Getenv(s)
 
-		if jpd.LoginData.UserID == "" {
-			panic(fmt.Sprintf("User ID Environment variable: %s  not defined\n", s))
+		if jpd.LoginData.UserID == "" && generic.ConfData.HttpMode != generic.HttpModeReplay {
+			return fmt.Errorf("User ID Environment variable: %s not defined", s)
 		}
 	}
+	return nil
 }
 
-func (jpd *JpData) Process() {
-	client := jpd.InitClient()
-
-	//retrieve the  AIP document and the active one
-	var aipDocsList AipDocs
+/*
+	Run the full pipeline on the active document.
+	An error is returned if the login, the editions or the airports list cannot be retrieved.
+	The errors related to the en-route data, an airport or a file do not stop the process,
+	they are provided in the failure report.
+*/
+func (jpd *JpData) Process() (*generic.FailureReport, error) {
+	client, err := jpd.InitClient()
+	if err != nil {
+		return nil, err
+	}
 
-	fmt.Println("Retrieve the AIP Documents")
-	aipDocsList = getAipDocuments(&client)
-	fmt.Println("Retrieve the Active Document")
-	activeAipDoc := aipDocsList.getActiveAipDoc()
-	activeAipDoc.NextEffectiveDate = aipDocsList.GetNextDate(*activeAipDoc)
-	activeAipDoc.CountryCode = jpd.CountryDir
-	activeAipDoc.ProcessDate = time.Now()
-	fmt.Println("Active Document Effective Date:" + activeAipDoc.EffectiveDate.Format("02-Jan-2006") +
-		" Publication Date: " + activeAipDoc.PublicationDate.Format("02-Jan-2006"))
-	fmt.Println("   " + activeAipDoc.FullURLDir)
+	activeAipDoc, err := jpd.activeAipDocument(&client)
+	if err != nil {
+		return nil, err
+	}
 
+	//the en-route data are kept in the document, so they are written in the report
 	fmt.Println("Retrieve the Navaids List")
-	activeAipDoc.GetNavaids(&client)
+	if _, err := activeAipDoc.GetNavaids(&client); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	fmt.Println("Retrieve the Waypoints List")
+	if _, err := activeAipDoc.GetWaypoints(&client); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	fmt.Println("Retrieve the ATS Routes")
+	if _, err := activeAipDoc.GetRoutes(&client); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	fmt.Println("Retrieve the Airspaces")
+	if _, err := activeAipDoc.GetAirspaces(&client); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	fmt.Println("Retrieve the Prohibited, Restricted and Danger Areas")
+	if _, err := activeAipDoc.GetRestrictedAreas(&client); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	fmt.Println("Retrieve the Air Navigation Obstacles")
+	if _, err := activeAipDoc.GetObstacles(&client); err != nil {
+		activeAipDoc.AddError(err)
+	}
 
 	fmt.Println("Retrieve the Airports List")
-	activeAipDoc.LoadAirports(&client)
+	if err := activeAipDoc.LoadAirports(&client); err != nil {
+		return generic.CollectFailures(activeAipDoc), err
+	}
 	//activeAipDoc.DownloadAllAiportsHtmlPage(&client)
-	fmt.Println("Number of identified airports: ")
+	fmt.Printf("Number of identified airports: %d \n", len(activeAipDoc.Airports))
 
 	fmt.Println("Download the Airports Data")
-	activeAipDoc.DownloadAllAiportsData(&client)
+	activeAipDoc.DownloadAllAiportsData(&client, true)
+
+	//write the report JSON file and the chart catalog in the edition directory
+	//so several countries can be processed during the same run
+	if err := generic.WriteReport(activeAipDoc); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	if err := generic.WriteChartCatalog(activeAipDoc); err != nil {
+		activeAipDoc.AddError(err)
+	}
+	return generic.CollectFailures(activeAipDoc), nil
+}
+
+/*
+	Retrieve the AIP documents (editions) listed in the main AIP page.
+*/
+func (jpd *JpData) Editions(cl *http.Client) ([]generic.AipDocument, error) {
+	docs, err := getAipDocuments(cl)
+	if err != nil {
+		return nil, err
+	}
+	var editions []generic.AipDocument
+	for _, d := range docs {
+		editions = append(editions, d.AipDocument)
+	}
+	return editions, nil
+}
 
-	//write the report JSON file
-	jsonData, err := json.MarshalIndent(activeAipDoc, "", " ")
+/*
+	Retrieve the active AIP document.
+	The next effective date, the country code and the process date are set.
+*/
+func (jpd *JpData) ActiveDocument(cl *http.Client) (generic.IAipDocument, error) {
+	doc, err := jpd.activeAipDocument(cl)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
-	_ = ioutil.WriteFile("info.json", jsonData, 0644)
+	return doc, nil
+}
+
+func (jpd *JpData) activeAipDocument(cl *http.Client) (*JpAipDocument, error) {
+	fmt.Println("Retrieve the AIP Documents")
+	aipDocsList, err := getAipDocuments(cl)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("Retrieve the Active Document")
+	activeAipDoc, err := aipDocsList.getActiveAipDoc()
+	if err != nil {
+		return nil, err
+	}
+	activeAipDoc.CountryCode = jpd.CountryDir
+	//the edition directory is known, load its manifest before any concurrent use
+	activeAipDoc.Manifest()
+	activeAipDoc.NextEffectiveDate, err = aipDocsList.GetNextDate(activeAipDoc)
+	if err != nil {
+		//the next edition is not always published, assume the next AIRAC cycle
+		activeAipDoc.NextEffectiveDate = activeAipDoc.EffectiveDate.AddDate(0, 0, 28)
+		log.Printf("%s, next effective date set to %s \n", err, activeAipDoc.NextEffectiveDate.Format("02-Jan-2006"))
+	}
+	activeAipDoc.ProcessDate = time.Now()
+	fmt.Println("Active Document Effective Date:" + activeAipDoc.EffectiveDate.Format("02-Jan-2006") +
+		" Publication Date: " + activeAipDoc.PublicationDate.Format("02-Jan-2006"))
+	fmt.Println("   " + activeAipDoc.FullURLDir)
+	return activeAipDoc, nil
 }
 
 /**
  * initClient inits an http client to connect to the website  by sending the
  * data to the formular.
  */
-func (jpd *JpData) InitClient() http.Client {
+func (jpd *JpData) InitClient() (http.Client, error) {
 
 	frmData := jpd.LoginData
 	//Create a cookie Jar to manage the login cookies
 	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 	if err != nil {
-		log.Fatal(err)
+		return http.Client{}, err
 	}
 
 	/*
@@ -148,7 +241,7 @@ func (jpd *JpData) InitClient() http.Client {
 
 		var client = http.Client{Jar: jar, Transport: tr}
 	*/
-	var client = http.Client{Jar: jar}
+	var client = http.Client{Jar: jar, Transport: generic.NewTransport()}
 	//login to the page
 	v := url.Values{"formName": {frmData.FormName},
 		"password": {frmData.Password},
@@ -158,9 +251,12 @@ func (jpd *JpData) InitClient() http.Client {
 	resp, err := client.PostForm(JapanAis.LoginPage, v)
 	if err != nil {
 		log.Println("If error due to certificate problem, install ca-certificates")
-		log.Fatal(err)
+		return client, err
 	}
 
 	defer resp.Body.Close()
-	return client
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return client, fmt.Errorf("login on %s failed: %s", JapanAis.LoginPage, resp.Status)
+	}
+	return client, nil
 }
@@ -0,0 +1,368 @@
+package japan
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+var mockAirports = []mockAirport{
+	{icao: "RJTT", name: "Tokyo Intl", charts: []string{"JP-AD-2-RJTT-2.24.1-en-JP.pdf", "JP-AD-2-RJTT-2.24.2-en-JP.pdf"}},
+	{icao: "RJSA", name: "Aomori"},
+}
+
+// setupMockRun configures the Japan provider and the data directory for a run against the mock portal.
+// The previous configuration is restored at the end of the test.
+func setupMockRun(t *testing.T, m *mockAis) string {
+	dataDir, err := ioutil.TempDir("", "aipdownloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedConf, savedAis := generic.ConfData, JapanAis
+	t.Cleanup(func() {
+		generic.ConfData, JapanAis = savedConf, savedAis
+		os.RemoveAll(dataDir)
+	})
+
+	generic.ConfData = generic.ConfigurationDataStruct{
+		MainLocalDir: dataDir,
+		MergeDir:     "merge",
+		Retry:        generic.RetryPolicy{MaxAttempts: 2, BaseDelayMs: 1, MaxDelayMs: 1},
+	}
+	JapanAis = m.config()
+	return dataDir
+}
+
+func editionDir(dataDir string) string {
+	return filepath.Join(dataDir, "Japan", "20201008")
+}
+
+func assertFilesExist(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		if st, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("%s: %s", f, err)
+		} else if st.Size() == 0 {
+			t.Errorf("%s is empty", f)
+		}
+	}
+}
+
+func TestProcessAgainstMockPortal(t *testing.T) {
+	m := newMockAis(t, mockAirports)
+	dataDir := setupMockRun(t, m)
+
+	report, err := JapanAis.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() != 0 {
+		report.Print(os.Stderr)
+		t.Fatalf("expected no failure, got %d", report.Count())
+	}
+
+	dir := editionDir(dataDir)
+	assertFilesExist(t, dir,
+		"info.json",
+		generic.ManifestFileName,
+		"RJTT/RJTT.html",
+		"RJTT/JP-AD-2-RJTT-en-JP.pdf",
+		"RJTT/JP-AD-2-RJTT-2.24.1-en-JP.pdf",
+		"RJTT/JP-AD-2-RJTT-2.24.2-en-JP.pdf",
+		"RJSA/RJSA.html",
+		"RJSA/JP-AD-2-RJSA-en-JP.pdf",
+		"merge/RJTT_full.pdf",
+		"merge/RJTT_chart.pdf",
+		"merge/RJSA_full.pdf",
+	)
+
+	//a merged file of a single source file has its outline as well
+	if _, titles := readOutline(t, filepath.Join(dir, "merge/RJSA_full.pdf")); strings.Join(titles, "|") != "RJSA AD 2 p0" {
+		t.Errorf("unexpected RJSA outline %v", titles)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "info.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		IsActive          bool
+		CountryCode       string
+		NextEffectiveDate string
+		Navaids           []generic.Navaid
+		Waypoints         []generic.Waypoint
+		Routes            []generic.Route
+		Airspaces         []generic.Airspace
+		RestrictedAreas   []generic.RestrictedArea
+		Obstacles         []generic.Obstacle
+		Airports          []struct {
+			Icao      string
+			Title     string
+			AdminData generic.AdminData
+			Com       []generic.ComData
+			Runways   []generic.Runway
+			Airspaces []generic.Airspace
+			Obstacles []generic.Obstacle
+		}
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsActive || info.CountryCode != "Japan" {
+		t.Errorf("unexpected document: active %t country %s", info.IsActive, info.CountryCode)
+	}
+	if info.NextEffectiveDate != "2068-11-05T00:00:00Z" {
+		t.Errorf("unexpected next effective date %s", info.NextEffectiveDate)
+	}
+	if len(info.Navaids) != 1 || info.Navaids[0].Id != "HWE" || info.Navaids[0].Position.Latitude == 0 {
+		t.Errorf("unexpected navaids %+v", info.Navaids)
+	}
+	if len(info.Waypoints) != 2 || info.Waypoints[1].Id != "ADDUM" {
+		t.Errorf("unexpected waypoints %+v", info.Waypoints)
+	}
+	if len(info.Routes) != 2 || len(info.Routes[0].Segments) != 2 {
+		t.Errorf("unexpected routes %+v", info.Routes)
+	}
+	if len(info.Airspaces) != 3 || len(info.Airspaces[1].Boundary) == 0 {
+		t.Errorf("unexpected airspaces %+v", info.Airspaces)
+	}
+	if len(info.RestrictedAreas) != 2 || info.RestrictedAreas[0].Centre == nil {
+		t.Errorf("unexpected restricted areas %+v", info.RestrictedAreas)
+	}
+	if len(info.Obstacles) != 2 || info.Obstacles[0].Id != "JP0001" {
+		t.Errorf("unexpected obstacles %+v", info.Obstacles)
+	}
+	var icaos []string
+	for _, apt := range info.Airports {
+		icaos = append(icaos, apt.Icao)
+		if apt.Icao == "RJTT" && apt.Title != "Tokyo Intl" {
+			t.Errorf("unexpected RJTT title %q", apt.Title)
+		}
+		if apt.AdminData.ArpCoord.Latitude == 0 || apt.AdminData.Elevation.Unit != "FT" {
+			t.Errorf("%s reference data not reported: %+v", apt.Icao, apt.AdminData)
+		}
+		if len(apt.Com) != 3 || apt.Com[0].CallSign != "Tokyo Tower" {
+			t.Errorf("%s communication facilities not reported: %+v", apt.Icao, apt.Com)
+		}
+		if len(apt.Runways) != 2 || apt.Runways[1].Lda.Value != 2700 {
+			t.Errorf("%s runways not reported: %+v", apt.Icao, apt.Runways)
+		}
+		if len(apt.Airspaces) != 2 || apt.Airspaces[0].Type != "CTR" || len(apt.Airspaces[0].Boundary) == 0 {
+			t.Errorf("%s airspaces not reported: %+v", apt.Icao, apt.Airspaces)
+		}
+		if len(apt.Obstacles) != 2 || apt.Obstacles[0].Airport != apt.Icao {
+			t.Errorf("%s obstacles not reported: %+v", apt.Icao, apt.Obstacles)
+		}
+	}
+	sort.Strings(icaos)
+	if len(icaos) != 2 || icaos[0] != "RJSA" || icaos[1] != "RJTT" {
+		t.Errorf("unexpected airports %v", icaos)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, "charts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var catalog []generic.ChartEntry
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 2 || catalog[0].Airport != "RJTT" || catalog[0].Title != "JP-AD-2-RJTT-2.24.1-en-JP.pdf" {
+		t.Errorf("unexpected chart catalog %+v", catalog)
+	}
+}
+
+func TestProcessReportsMissingChart(t *testing.T) {
+	airports := []mockAirport{
+		{icao: "RJTT", name: "Tokyo Intl", charts: []string{"JP-AD-2-RJTT-2.24.1-en-JP.pdf"}},
+		{icao: "RJFF", name: "Fukuoka", charts: []string{"JP-AD-2-RJFF-2.24.1-en-JP.pdf"},
+			missing: []string{"JP-AD-2-RJFF-2.24.2-en-JP.pdf"}},
+	}
+	m := newMockAis(t, airports)
+	dataDir := setupMockRun(t, m)
+
+	report, err := JapanAis.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() == 0 {
+		t.Fatal("expected failures for the missing chart")
+	}
+	for _, f := range report.Failures {
+		if f.Airport != "RJFF" {
+			t.Errorf("unexpected failure %s", f)
+		}
+	}
+	if !report.ExceedsThreshold(0) || report.ExceedsThreshold(report.Count()) {
+		t.Errorf("threshold not respected for %d failures", report.Count())
+	}
+
+	//the other airport is not affected
+	dir := editionDir(dataDir)
+	assertFilesExist(t, dir, "merge/RJTT_full.pdf", "merge/RJTT_chart.pdf", "RJFF/JP-AD-2-RJFF-2.24.1-en-JP.pdf")
+	if _, err := os.Stat(filepath.Join(dir, "RJFF", "JP-AD-2-RJFF-2.24.2-en-JP.pdf")); !os.IsNotExist(err) {
+		t.Errorf("the missing chart shall not be saved: %v", err)
+	}
+}
+
+func TestProcessHeliport(t *testing.T) {
+	airports := []mockAirport{
+		{icao: "RJTT", name: "Tokyo Intl", charts: []string{"JP-AD-2-RJTT-2.24.1-en-JP.pdf"}},
+		{icao: "RJTI", name: "Tokyo Heliport", charts: []string{"JP-AD-3-RJTI-3.23.1-en-JP.pdf"}, heliport: true},
+	}
+	m := newMockAis(t, airports)
+	dataDir := setupMockRun(t, m)
+
+	report, err := JapanAis.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() != 0 {
+		report.Print(os.Stderr)
+		t.Fatalf("expected no failure, got %d", report.Count())
+	}
+
+	dir := editionDir(dataDir)
+	assertFilesExist(t, dir,
+		"RJTI/RJTI.html",
+		"RJTI/JP-AD-3-RJTI-en-JP.pdf",
+		"RJTI/JP-AD-3-RJTI-3.23.1-en-JP.pdf",
+		"merge/RJTI_full.pdf",
+		"merge/RJTI_chart.pdf",
+		"merge/RJTT_full.pdf",
+	)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "info.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		Airports []struct {
+			Icao        string
+			Title       string
+			AirportType string
+			AdminData   generic.AdminData
+			Runways     []generic.Runway
+			Com         []generic.ComData
+		}
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		t.Fatal(err)
+	}
+	types := make(map[string]string)
+	for _, apt := range info.Airports {
+		types[apt.Icao] = apt.AirportType
+		if apt.Icao != "RJTI" {
+			continue
+		}
+		if apt.Title != "Tokyo Heliport" || apt.AdminData.ArpCoord.Latitude == 0 || len(apt.Com) != 3 {
+			t.Errorf("unexpected heliport %+v", apt)
+		}
+		if len(apt.Runways) != 0 {
+			t.Errorf("a heliport has no runway, got %+v", apt.Runways)
+		}
+	}
+	if types["RJTI"] != generic.HeliportType || types["RJTT"] != generic.AerodromeType {
+		t.Errorf("unexpected airport types %v", types)
+	}
+}
+
+func TestProcessMergeProfiles(t *testing.T) {
+	m := newMockAis(t, mockAirports)
+	dataDir := setupMockRun(t, m)
+	generic.ConfData.MergeProfiles = []generic.MergeProfile{
+		{Name: "text only", Include: []string{"Text"}, Output: "{ICAO}_text.pdf"},
+		{Name: "pack", Include: []string{"*-2.24.2-*", "Chart"}, Output: "{ICAO}_{NAME}.pdf"},
+	}
+	if err := generic.ConfData.CheckMergeProfiles(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := JapanAis.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() != 0 {
+		report.Print(os.Stderr)
+		t.Fatalf("expected no failure, got %d", report.Count())
+	}
+
+	dir := editionDir(dataDir)
+	assertFilesExist(t, dir, "merge/RJTT_text.pdf", "merge/RJTT_pack.pdf", "merge/RJSA_text.pdf")
+	//no file without profile, no file for a profile without selected file
+	for _, f := range []string{"merge/RJTT_full.pdf", "merge/RJTT_chart.pdf", "merge/RJSA_pack.pdf"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); !os.IsNotExist(err) {
+			t.Errorf("%s shall not be produced: %v", f, err)
+		}
+	}
+}
+
+func TestProcessSecondRunUsesManifest(t *testing.T) {
+	m := newMockAis(t, mockAirports)
+	setupMockRun(t, m)
+
+	if _, err := JapanAis.Process(); err != nil {
+		t.Fatal(err)
+	}
+	chart := "/html/AIP/html/" + mockEditionPath + "pdf/JP-AD-2-RJTT-2.24.1-en-JP.pdf"
+	if n := m.requestCount(chart); n != 1 {
+		t.Fatalf("chart requested %d times during the first run", n)
+	}
+
+	report, err := JapanAis.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() != 0 {
+		t.Fatalf("expected no failure, got %d", report.Count())
+	}
+	if n := m.requestCount(chart); n != 1 {
+		t.Errorf("chart requested again during the second run (%d requests)", n)
+	}
+}
+
+func TestProcessLoginFailure(t *testing.T) {
+	m := newMockAis(t, mockAirports)
+	setupMockRun(t, m)
+	JapanAis.LoginData.Password = "wrong"
+
+	if _, err := JapanAis.Process(); err == nil {
+		t.Fatal("expected a login error")
+	}
+}
+
+func TestProcessRecordAndReplay(t *testing.T) {
+	m := newMockAis(t, mockAirports)
+	setupMockRun(t, m)
+
+	archive, err := ioutil.TempDir("", "aiparchive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(archive)
+
+	generic.ConfData.HttpMode, generic.ConfData.HttpArchiveDir = generic.HttpModeRecord, archive
+	if _, err := JapanAis.Process(); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	//replay in a new data directory, without the portal
+	replayDir := setupMockRun(t, m)
+	generic.ConfData.HttpMode, generic.ConfData.HttpArchiveDir = generic.HttpModeReplay, archive
+	report, err := JapanAis.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count() != 0 {
+		report.Print(os.Stderr)
+		t.Fatalf("expected no failure, got %d", report.Count())
+	}
+	assertFilesExist(t, editionDir(replayDir), "info.json", "merge/RJTT_full.pdf", "merge/RJTT_chart.pdf", "merge/RJSA_full.pdf")
+}
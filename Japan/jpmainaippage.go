@@ -11,36 +11,39 @@ import (
 	"strings"
 	"time"
 
+	"github.com/NagoDede/aipdownloader/generic"
 	"github.com/PuerkitoBio/goquery"
 )
 
 type AipDocs []*JpAipDocument
 
-func getAipDocuments(cl *http.Client) AipDocs {
-	resp, err := cl.Get(JapanAis.MainAipPage)
+func getAipDocuments(cl *http.Client) (AipDocs, error) {
+	resp, err := generic.GetWithRetry(cl, JapanAis.MainAipPage)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	defer resp.Body.Close()
 
-	availableDocs := getActiveAipDocument(resp.Body)
-	return availableDocs
+	return getActiveAipDocument(resp.Body)
 }
 
 /**
  * getActiveAipDocument identifies the documents from the AIP main page.
- * It returns a table of AipDocuments
+ * It returns a table of AipDocuments.
+ * A row which cannot be decoded is disregarded. An error is returned if
+ * the page cannot be parsed or if no document can be identified.
  */
-func getActiveAipDocument(mainaip io.ReadCloser) AipDocs {
+func getActiveAipDocument(mainaip io.Reader) (AipDocs, error) {
 	var aipDocs = AipDocs{}
 
 	doc, err := goquery.NewDocumentFromReader(mainaip)
 	if err != nil {
-		fmt.Println("No url found")
-		log.Fatal(err)
+		return nil, fmt.Errorf("unable to parse the AIP main page: %s", err)
 	}
 
+	var rowErrs []error
+
 	var tempEffectiveDate time.Time
 	doc.Find("table").Each(func(index int, tablehtml *goquery.Selection) {
 		//The references of the documents are recorded in the tables with class Table-all-0-left
@@ -53,7 +56,7 @@ func getActiveAipDocument(mainaip io.ReadCloser) AipDocs {
 					var effectiveDate time.Time
 					var publicationDate time.Time
 					var partialURL string
-					var err error
+					var rowErr error
 					aipdoc := JpAipDocument{}
 
 					//run across the cells
@@ -62,21 +65,21 @@ func getActiveAipDocument(mainaip io.ReadCloser) AipDocs {
 						//The content of the cell "effective" cell will be used to confirm this date
 						if tablecell.HasClass("current") {
 							tempCurrent, exist := tablecell.Find("span").Attr("id")
-							if exist {
+							if exist && strings.HasPrefix(tempCurrent, "efct-") {
 								cleanStr := tempCurrent[len("efct-"):]
+								var err error
 								currentDate, err = buildDateFromYYYYMMDD(cleanStr)
 								if err != nil {
-									fmt.Printf("2-Unable to build date from %s \n", cleanStr)
-									panic(err)
+									rowErr = fmt.Errorf("2-Unable to build date from %s: %s", cleanStr, err)
 								}
 							}
 						}
 
 						if tablecell.HasClass("date") && !tablecell.HasClass("td-right-top-0-0 date") {
+							var err error
 							effectiveDate, err = buildDateFromDD_MMM_YYYY(tablecell.Text())
 							if err != nil {
-								fmt.Printf("1-Unable to build date from %s \n", tablecell.Text())
-								panic(err)
+								rowErr = fmt.Errorf("1-Unable to build date from %s: %s", tablecell.Text(), err)
 							}
 
 							//retrieve the address
@@ -89,14 +92,19 @@ func getActiveAipDocument(mainaip io.ReadCloser) AipDocs {
 						}
 
 						if tablecell.HasClass("td-right-top-0-0 date") {
+							var err error
 							publicationDate, err = buildDateFromDD_MMM_YYYY(tablecell.Text())
 							if err != nil {
-								fmt.Printf("4-Unable to build date from %s \n", tablecell.Text())
-								panic(err)
+								rowErr = fmt.Errorf("4-Unable to build date from %s: %s", tablecell.Text(), err)
 							}
 						}
 					})
 
+					if rowErr != nil {
+						rowErrs = append(rowErrs, rowErr)
+						return
+					}
+
 					//after review of the cells, there is enough data to create an AipDocument
 					//create the aipdoc
 					aipdoc.EffectiveDate = effectiveDate
@@ -127,13 +135,13 @@ func getActiveAipDocument(mainaip io.ReadCloser) AipDocs {
 					//we retrieve the dates from the Url and compare with the extracted data
 					pubDateURL, err := getPublicationDateFromPartialURL(partialURL)
 					if err != nil {
-						fmt.Printf("6-Unable to get Publication date from %s \n", partialURL)
-						panic(err)
+						rowErrs = append(rowErrs, fmt.Errorf("6-Unable to get Publication date from %s: %s", partialURL, err))
+						return
 					}
 					effDateURL, err := getEffectiveDateFromPartialURL(partialURL)
 					if err != nil {
-						fmt.Printf("7-Unable to get Effective date from %s \n", partialURL)
-						panic(err)
+						rowErrs = append(rowErrs, fmt.Errorf("7-Unable to get Effective date from %s: %s", partialURL, err))
+						return
 					}
 					if effectiveDate.Equal(effDateURL) && publicationDate.Equal(pubDateURL) {
 						aipdoc.IsPartialURLValid = true
@@ -149,11 +157,18 @@ func getActiveAipDocument(mainaip io.ReadCloser) AipDocs {
 		}
 	})
 
+	for _, err := range rowErrs {
+		log.Printf("AIP document disregarded: %s \n", err)
+	}
+	if len(aipDocs) == 0 {
+		return nil, fmt.Errorf("no AIP document identified in the AIP main page (%d row(s) disregarded)", len(rowErrs))
+	}
+
 	//setActiveAipDoc(aipDocs, tempEffectiveDate)
 	aipDocs.setActiveAipDoc(tempEffectiveDate)
 	//aipDocs.printAipDocs()
 
-	return aipDocs
+	return aipDocs, nil
 }
 
 /***
@@ -178,7 +193,7 @@ func (docs *AipDocs) setActiveAipDoc(targetDate time.Time) {
 	}
 }
 
-func (docs *AipDocs) getActiveAipDoc() *JpAipDocument {
+func (docs *AipDocs) getActiveAipDoc() (*JpAipDocument, error) {
 	var activeDocs []*JpAipDocument
 
 	//count the number of active document
@@ -194,11 +209,11 @@ func (docs *AipDocs) getActiveAipDoc() *JpAipDocument {
 	}
 
 	if counter == 1 {
-		return activeDoc
+		return activeDoc, nil
 	}
 
 	if counter == 0 {
-		panic("No document identified as Active")
+		return nil, errors.New("No document identified as Active")
 	}
 
 	//for the other cases, need to sort by publication date
@@ -215,7 +230,7 @@ func (docs *AipDocs) getActiveAipDoc() *JpAipDocument {
 		}
 	}
 	fmt.Printf("Selected Active document is effective date: %s - publication date %s \n", activeDoc.EffectiveDate, activeDoc.PublicationDate)
-	return activeDoc
+	return activeDoc, nil
 }
 
 func (docs AipDocs) printAipDoc() {
@@ -231,7 +246,10 @@ func (docs AipDocs) printAipDoc() {
  * DD MMM YYYY (i.e. 14 jul 2019, 1 Mar 2018)
  */
 func buildDateFromDD_MMM_YYYY(s string) (time.Time, error) {
-	strTable := strings.Split(s, " ")
+	strTable := strings.Fields(s)
+	if len(strTable) != 3 {
+		return time.Now(), errors.New("Unable to convert " + s + " to a date. Confirm the format is DD MMM YYYY (14 jul 2019)")
+	}
 	day := strTable[0]
 	if len(day) == 1 {
 		day = "0" + day
@@ -262,6 +280,9 @@ func buildDateFromDD_MMM_YYYY(s string) (time.Time, error) {
  * buildData builds a date (time.Time), initiliazed at 0:0:0Z from a date with the YYYYMMDD date
  */
 func buildDateFromYYYYMMDD(s string) (time.Time, error) {
+	if len(s) < 8 {
+		return time.Now(), errors.New("Unable to convert " + s + " to a date. Confirm format is YYYYMMDD")
+	}
 	efctYear := s[0:4]
 	efctMth := s[4:6]
 	efctDay := s[6:8]
@@ -299,6 +320,9 @@ func buildDate(year string, month string, day string) (time.Time, error) {
  */
 func getEffectiveDateFromPartialURL(pth string) (time.Time, error) {
 	strTable := strings.Split(pth, "/")
+	if len(strTable) < 3 {
+		return time.Now(), errors.New("Unable to extract the effective date from the URL " + pth)
+	}
 
 	date, err := buildDateFromYYYYMMDD(strTable[2])
 	if err != nil {
@@ -322,7 +346,7 @@ func getPublicationDateFromPartialURL(pth string) (time.Time, error) {
 	return date, nil
 }
 
-func (docs AipDocs) GetNextDate(actDoc JpAipDocument) time.Time {
+func (docs AipDocs) GetNextDate(actDoc *JpAipDocument) (time.Time, error) {
 
 	sort.SliceStable(docs, func(i, j int) bool {
 		return docs[i].EffectiveDate.Before(docs[j].EffectiveDate)
@@ -331,9 +355,8 @@ func (docs AipDocs) GetNextDate(actDoc JpAipDocument) time.Time {
 	for _, d := range docs {
 		if d.EffectiveDate.After(actDoc.EffectiveDate) {
 			fmt.Printf("Next date is %s \n", d.EffectiveDate)
-			return d.EffectiveDate
+			return d.EffectiveDate, nil
 		}
 	}
-	log.Panic("Unable to identify the next date")
-	return time.Now()
+	return time.Now(), errors.New("Unable to identify the next date")
 }
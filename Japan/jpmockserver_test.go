@@ -0,0 +1,495 @@
+package japan
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Path of the edition published by the mock portal, relative to the active URL
+const mockEditionPath = "20201008/eAIP/20201008/"
+
+/*
+ mockAirport describes an airport published by the mock portal.
+ The missing charts are listed in the airport page but answer 404.
+ A heliport is published in the AD 3 part, the other airports in the AD 2 part.
+*/
+type mockAirport struct {
+	icao     string
+	name     string
+	charts   []string
+	missing  []string
+	heliport bool
+}
+
+// part provides the part of the AIP publishing the airport.
+func (apt mockAirport) part() string {
+	if apt.heliport {
+		return "3"
+	}
+	return "2"
+}
+
+/*
+ mockAis is a fake of the Japan AIS portal: login form, editions table,
+ AIP index, navaids page, airport pages and PDF files.
+ All the pages except the login page require the session cookie set by the login.
+*/
+type mockAis struct {
+	*httptest.Server
+	airports []mockAirport
+	pdf      []byte
+	mu       sync.Mutex
+	requests map[string]int
+}
+
+func newMockAis(t *testing.T, airports []mockAirport) *mockAis {
+	m := &mockAis{airports: airports, pdf: minimalPdf(), requests: make(map[string]int)}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// config provides the Japan configuration pointing to the mock portal.
+func (m *mockAis) config() JpData {
+	return JpData{
+		MainDataConfig: MainDataConfig{
+			CountryDir:       "Japan",
+			MainAipPage:      m.URL + "/html/AIP/html/DomesticAIP.do",
+			MainAipActiveURL: m.URL + "/html/AIP/html/",
+		},
+		LoginData:        JpLoginFormData{FormName: "ais-web", Password: "pwd", UserID: "user"},
+		LoginPage:        m.URL + "/LoginAction.do",
+		AipIndexPageName: "JP-menu-en-JP.html",
+	}
+}
+
+// requestCount provides the number of requests received for the path.
+func (m *mockAis) requestCount(path string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests[path]
+}
+
+func (m *mockAis) serve(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requests[r.URL.Path]++
+	m.mu.Unlock()
+
+	if r.URL.Path == "/LoginAction.do" {
+		m.serveLogin(w, r)
+		return
+	}
+	if c, err := r.Cookie("JSESSIONID"); err != nil || c.Value != "mock-session" {
+		http.Error(w, "not logged in", http.StatusForbidden)
+		return
+	}
+
+	editionDir := "/html/AIP/html/" + mockEditionPath
+	switch {
+	case r.URL.Path == "/html/AIP/html/DomesticAIP.do":
+		m.writeHtml(w, m.editionsPage())
+	case r.URL.Path == editionDir+"JP-menu-en-JP.html":
+		m.writeHtml(w, m.indexPage())
+	case r.URL.Path == editionDir+"JP-ENR-4.1-en-JP.html":
+		m.writeHtml(w, mockNavaidsPage)
+	case r.URL.Path == editionDir+"JP-ENR-4.4-en-JP.html":
+		m.writeHtml(w, mockWaypointsPage)
+	case r.URL.Path == editionDir+"JP-ENR-3.1-en-JP.html":
+		m.writeHtml(w, mockRoutesPage)
+	case r.URL.Path == editionDir+"JP-ENR-2.1-en-JP.html":
+		m.writeHtml(w, mockAirspacesPage)
+	case r.URL.Path == editionDir+"JP-ENR-5.1-en-JP.html":
+		m.writeHtml(w, mockRestrictedAreasPage)
+	case r.URL.Path == editionDir+"JP-ENR-5.4-en-JP.html":
+		m.writeHtml(w, mockObstaclesPage)
+	case strings.HasPrefix(r.URL.Path, editionDir+"JP-AD-") && strings.HasSuffix(r.URL.Path, "-en-JP.html"):
+		for _, apt := range m.airports {
+			if r.URL.Path == editionDir+"JP-AD-"+apt.part()+"-"+apt.icao+"-en-JP.html" {
+				if apt.heliport {
+					m.writeHtml(w, m.heliportPage(apt))
+				} else {
+					m.writeHtml(w, m.airportPage(apt))
+				}
+				return
+			}
+		}
+		http.NotFound(w, r)
+	case strings.HasPrefix(r.URL.Path, editionDir+"pdf/"):
+		name := strings.TrimPrefix(r.URL.Path, editionDir+"pdf/")
+		for _, apt := range m.airports {
+			for _, missing := range apt.missing {
+				if missing == name {
+					http.NotFound(w, r)
+					return
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write(m.pdf)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (m *mockAis) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	if r.PostForm.Get("formName") != "ais-web" || r.PostForm.Get("userID") != "user" ||
+		r.PostForm.Get("password") != "pwd" {
+		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "mock-session", Path: "/"})
+	m.writeHtml(w, "<html><body>Welcome</body></html>")
+}
+
+func (m *mockAis) writeHtml(w http.ResponseWriter, page string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+// editionsPage lists the active edition and a future one.
+func (m *mockAis) editionsPage() string {
+	return `<html><body>
+<table class="Table-all-0-left">
+<tr><th>Effective</th><th>Publication</th></tr>
+<tr class="odd-row">
+ <td class="current"><span id="efct-20201008"></span></td>
+ <td class="date"><a href="` + mockEditionPath + `index.html">8 Oct 2020</a></td>
+ <td class="td-right-top-0-0 date">8 Oct 2020</td>
+</tr>
+<tr class="even-row">
+ <td class="current"><span id="efct-20681105"></span></td>
+ <td class="date"><a href="20681001/eAIP/20681105/index.html">5 Nov 2068</a></td>
+ <td class="td-right-top-0-0 date">1 Oct 2068</td>
+</tr>
+</table>
+</body></html>`
+}
+
+func (m *mockAis) indexPage() string {
+	var b strings.Builder
+	b.WriteString(`<html><body>
+<div id="ENR-2details">
+ <div class="H3"><a title="ENR 2.1 FIR, UIR, TMA AND CTA" href="JP-ENR-2.1-en-JP.html">ENR 2.1</a></div>
+</div>
+<div id="ENR-5details">
+ <div class="H3"><a title="ENR 5.1 PROHIBITED, RESTRICTED AND DANGER AREAS" href="JP-ENR-5.1-en-JP.html">ENR 5.1</a></div>
+ <div class="H3"><a title="ENR 5.4 AIR NAVIGATION OBSTACLES" href="JP-ENR-5.4-en-JP.html">ENR 5.4</a></div>
+</div>
+<div id="ENR-3details">
+ <div class="H3"><a title="ENR 3.1 LOWER ATS ROUTES" href="JP-ENR-3.1-en-JP.html">ENR 3.1</a></div>
+ <div class="H3"><a title="ENR 3.6 EN-ROUTE HOLDING" href="JP-ENR-3.6-en-JP.html">ENR 3.6</a></div>
+</div>
+<div id="ENR-4details">
+ <div class="H3"><a title="ENR 4.1 RADIO NAVIGATION AIDS - EN-ROUTE" href="JP-ENR-4.1-en-JP.html">ENR 4.1</a></div>
+ <div class="H3"><a title="ENR 4.4 NAME-CODE DESIGNATORS FOR SIGNIFICANT POINTS" href="JP-ENR-4.4-en-JP.html">ENR 4.4</a></div>
+</div>
+<div id="AD-2details">
+`)
+	for _, apt := range m.airports {
+		if !apt.heliport {
+			fmt.Fprintf(&b, ` <div class="H3"><a title="%s AERODROME" id="AD-2.%s" href="JP-AD-2-%s-en-JP.html">%s - %s</a></div>
+`, apt.icao, apt.icao, apt.icao, apt.icao, apt.name)
+		}
+	}
+	b.WriteString("</div>\n<div id=\"AD-3details\">\n")
+	for _, apt := range m.airports {
+		if apt.heliport {
+			fmt.Fprintf(&b, ` <div class="H3"><a title="%s HELIPORT" id="AD-3.%s" href="JP-AD-3-%s-en-JP.html">%s - %s</a></div>
+`, apt.icao, apt.icao, apt.icao, apt.icao, apt.name)
+		}
+	}
+	b.WriteString("</div>\n</body></html>")
+	return b.String()
+}
+
+func (m *mockAis) airportPage(apt mockAirport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<html><body>
+<div id="%s-AD-2.1"><h4>%s AD 2.1 AERODROME LOCATION INDICATOR AND NAME</h4></div>
+%s
+%s
+%s
+%s
+%s
+<div id="%s-AD-2.24">
+<table><tbody>
+`, apt.icao, apt.icao, fmt.Sprintf(mockAdminDataSection, apt.icao, apt.icao),
+		fmt.Sprintf(mockObstaclesSection, apt.icao, apt.icao),
+		fmt.Sprintf(mockRunwaysSection, apt.icao, apt.icao, apt.icao, apt.icao),
+		fmt.Sprintf(mockAirspacesSection, apt.icao, apt.icao),
+		fmt.Sprintf(mockComDataSection, apt.icao, apt.icao), apt.icao)
+	for _, c := range append(append([]string{}, apt.charts...), apt.missing...) {
+		fmt.Fprintf(&b, `<tr><td>Chart</td><td><a href="pdf/%s">%s</a></td></tr>
+`, c, c)
+	}
+	b.WriteString("</tbody></table>\n</div>\n</body></html>")
+	return b.String()
+}
+
+// heliportPage is the AD 3 page of a heliport: the reference data, obstacles, airspace and
+// communication sections are the ones of an aerodrome, with the AD 3 numbering.
+func (m *mockAis) heliportPage(apt mockAirport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<html><body>
+<div id="%s-AD-3.1"><h4>%s AD 3.1 HELIPORT LOCATION INDICATOR AND NAME</h4></div>
+`, apt.icao, apt.icao)
+	for _, section := range []struct{ format, ad2, ad3 string }{
+		{mockAdminDataSection, "2.2", "3.2"},
+		{mockObstaclesSection, "2.10", "3.10"},
+		{mockAirspacesSection, "2.17", "3.16"},
+		{mockComDataSection, "2.18", "3.17"}} {
+		page := fmt.Sprintf(section.format, apt.icao, apt.icao)
+		page = strings.Replace(page, "-AD-"+section.ad2+`"`, "-AD-"+section.ad3+`"`, 1)
+		page = strings.Replace(page, "AD "+section.ad2+" ", "AD "+section.ad3+" ", 1)
+		b.WriteString(page + "\n")
+	}
+	fmt.Fprintf(&b, `<div id="%s-AD-3.23">
+<table><tbody>
+`, apt.icao)
+	for _, c := range append(append([]string{}, apt.charts...), apt.missing...) {
+		fmt.Fprintf(&b, `<tr><td>Chart</td><td><a href="pdf/%s">%s</a></td></tr>
+`, c, c)
+	}
+	b.WriteString("</tbody></table>\n</div>\n</body></html>")
+	return b.String()
+}
+
+// mockChartsSection is the AD 2.24 section of an airport page, formatted with the ICAO code.
+// The charts are identified by the label of their row and the text of their link.
+const mockChartsSection = `<div id="%s-AD-2.24"><h4>%s AD 2.24 CHARTS RELATED TO AN AERODROME</h4>
+<table><tbody>
+<tr><td>Aerodrome Chart - ICAO</td><td><a href="pdf/ADC.pdf">AERODROME CHART</a></td></tr>
+<tr><td>Aircraft Parking/Docking Chart - ICAO</td><td><a href="pdf/PDC.pdf">PARKING CHART 1</a></td></tr>
+<tr><td>Standard Departure Chart - Instrument (SID) - ICAO</td><td><a href="pdf/SID.pdf">LAXAS ONE DEPARTURE RWY 34L/34R</a></td></tr>
+<tr><td>Instrument Approach Chart - ICAO</td><td><a href="pdf/IAC1.pdf">ILS Z RWY34R</a><br/><a href="pdf/IAC2.pdf">RNAV (GNSS) Y RWY 22</a></td></tr>
+<tr><td>Others</td><td><a href="pdf/VAC.pdf">VISUAL APPROACH CHART RWY16L</a></td></tr>
+<tr><td>Others</td><td><a href="pdf/NOISE.pdf">NOISE ABATEMENT</a></td></tr>
+</tbody></table>
+</div>`
+
+// mockAdminDataSection is the AD 2.2 section of an airport page, formatted with the ICAO code.
+const mockAdminDataSection = `<div id="%s-AD-2.2"><h4>%s AD 2.2 AERODROME GEOGRAPHICAL AND ADMINISTRATIVE DATA</h4>
+<table><tbody>
+<tr><td>1</td><td>ARP coordinates and site at AD</td><td><p>353312N 1394652E</p><p>Intersection of RWY16R/34L and RWY04/22</p></td></tr>
+<tr><td>2</td><td>Direction and distance from (city)</td><td>8.5NM S of Tokyo Station</td></tr>
+<tr><td>3</td><td>Elevation/Reference temperature</td><td>21ft / 30.4°C (AUG)</td></tr>
+<tr><td>4</td><td>Geoid undulation at AD ELEV PSN</td><td>37m</td></tr>
+<tr><td>5</td><td>MAG VAR/Annual change</td><td>8°W (2020) / 0.1°W</td></tr>
+<tr><td>6</td><td>AD administration, address, telephone</td><td>Tokyo Airport Office</td></tr>
+<tr><td>7</td><td>Types of traffic permitted (IFR/VFR)</td><td>IFR/VFR</td></tr>
+<tr><td>8</td><td>Remarks</td><td>Nil</td></tr>
+</tbody></table>
+</div>`
+
+// mockObstaclesSection is the AD 2.10 section of an airport page, formatted with the ICAO code.
+// The obstacles have no designation, the elevation and the height share a column.
+const mockObstaclesSection = `<div id="%s-AD-2.10"><h4>%s AD 2.10 AERODROME OBSTACLES</h4>
+<table>
+<thead><tr><th>RWY/Area affected</th><th>Obstacle type</th><th>Coordinates</th><th>Elevation/HGT</th><th>Markings/LGT</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td><td>4</td><td>5</td><td>6</td></tr>
+<tr><td>RWY 34L APCH</td><td>Antenna</td><td><p>353130.00N</p><p>1394810.00E</p></td><td>198ft / 45m</td><td>Marked / LGTD</td><td>Nil</td></tr>
+<tr><td>Circling area</td><td>Building</td><td>353400N 1394500E</td><td>325ft / 300ft</td><td>Nil / LGTD</td><td>Nil</td></tr>
+</tbody></table>
+</div>`
+
+// mockRunwaysSection is the AD 2.12 and AD 2.13 sections of an airport page, formatted with the ICAO code.
+const mockRunwaysSection = `<div id="%s-AD-2.12"><h4>%s AD 2.12 RUNWAY PHYSICAL CHARACTERISTICS</h4>
+<table>
+<thead><tr><th>Designations RWY NR</th><th>TRUE BRG</th><th>Dimensions of RWY (M)</th><th>Strength (PCN) and surface of RWY and SWY</th><th>THR coordinates</th><th>THR elevation and highest elevation of TDZ</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td><td>4</td><td>5</td><td>6</td></tr>
+<tr><td>16R</td><td>157.64°</td><td>3000 x 60</td><td>PCN 125/R/A/W/T<br/>ASPH</td><td><p>353336.84N</p><p>1394650.64E</p></td><td>THR 20ft<br/>TDZ 21ft</td></tr>
+<tr><td>34L</td><td>337.65°</td><td>3000 x 60</td><td>PCN 125/R/A/W/T<br/>ASPH</td><td><p>353205.72N</p><p>1394735.31E</p></td><td>THR 18ft<br/>TDZ 19ft</td></tr>
+</tbody></table>
+</div>
+<div id="%s-AD-2.13"><h4>%s AD 2.13 DECLARED DISTANCES</h4>
+<table>
+<thead><tr><th>RWY Designator</th><th>TORA (M)</th><th>TODA (M)</th><th>ASDA (M)</th><th>LDA (M)</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>RWY 16R</td><td>3000</td><td>3060</td><td>3000</td><td>3000</td><td>Nil</td></tr>
+<tr><td>RWY 34L</td><td>3000</td><td>3300</td><td>3000</td><td>2700</td><td>Nil</td></tr>
+</tbody></table>
+</div>`
+
+// mockAirspacesSection is the AD 2.17 section of an airport page, formatted with the ICAO code.
+// The CTR is a circle centred on the ARP, the ATZ is a polygon.
+const mockAirspacesSection = `<div id="%s-AD-2.17"><h4>%s AD 2.17 ATS AIRSPACE</h4>
+<table><tbody>
+<tr><td>1</td><td>Designation and lateral limits</td><td><p>Tokyo CTR</p><p>A circle, radius 5NM centred on ARP</p></td></tr>
+<tr><td>2</td><td>Vertical limits</td><td>3000ft AMSL / SFC</td></tr>
+<tr><td>3</td><td>Airspace classification</td><td>D</td></tr>
+<tr><td>4</td><td>ATS unit call sign / Language</td><td>Tokyo Tower / English, Japanese</td></tr>
+<tr><td>5</td><td>Transition altitude</td><td>14000ft</td></tr>
+<tr><td>6</td><td>Remarks</td><td>Nil</td></tr>
+<tr><td>1</td><td>Designation and lateral limits</td><td>Haneda ATZ: 353500N 1394500E - 353500N 1395000E - 353000N 1395000E - 353000N 1394500E - 353500N 1394500E</td></tr>
+<tr><td>2</td><td>Vertical limits</td><td>1500ft AGL / SFC</td></tr>
+<tr><td>3</td><td>Airspace classification</td><td>G</td></tr>
+</tbody></table>
+</div>`
+
+// mockComDataSection is the AD 2.18 section of an airport page, formatted with the ICAO code.
+// The TWR service spans two rows.
+const mockComDataSection = `<div id="%s-AD-2.18"><h4>%s AD 2.18 ATS COMMUNICATION FACILITIES</h4>
+<table>
+<thead><tr><th>Service designation</th><th>Callsign</th><th>Frequency</th><th>Hours of operation</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td><td>4</td><td>5</td></tr>
+<tr><td rowspan="2">TWR</td><td rowspan="2">Tokyo Tower</td><td>118.1MHz<br/>124.35MHz</td><td>H24</td><td>Primary</td></tr>
+<tr><td>126.2MHz</td><td>H24</td><td>Military</td></tr>
+<tr><td>ATIS</td><td>Tokyo Airport Information</td><td>128.8MHz</td><td>H24</td><td>Nil</td></tr>
+</tbody></table>
+</div>`
+
+const mockNavaidsPage = `<html><body>
+<table><tbody>
+<tr id="NAV-1">
+ <td>HAKODATE<p>VOR/DME</p><p>(8°W/2020)</p></td>
+ <td>HWE</td>
+ <td>112.1MHz</td>
+ <td>H24</td>
+ <td><p>414611.00N</p><p>1404920.00E</p></td>
+ <td>100FT</td>
+ <td>Nil</td>
+</tr>
+</tbody></table>
+</body></html>`
+
+const mockWaypointsPage = `<html><body>
+<table>
+<thead><tr><th>Name-code designator</th><th>Coordinates</th><th>ATS route or other route</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td><td>4</td></tr>
+<tr><td>ADDUM</td><td><p>343852N</p><p>1395407E</p></td><td>Y11, Y111<br/>V17</td><td>Nil</td></tr>
+<tr><td>ABSAK</td><td><p>421043N</p><p>1410652E</p></td><td>A1</td><td>FIR boundary</td></tr>
+</tbody></table>
+</body></html>`
+
+// mockRoutesPage holds the route A1 (ABSAK - HWE - ADDUM) and the one way route Y11 (ADDUM - ABSAK).
+const mockRoutesPage = `<html><body>
+<table>
+<thead><tr><th>Route designator / Significant points</th><th>Track</th><th>DIST (NM)</th><th>Upper / Lower limits</th><th>Direction</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td colspan="6"><strong>A1</strong></td></tr>
+<tr><td>▲ ABSAK<br/>421043N 1410652E</td></tr>
+<tr><td>204°/024°</td><td>25.3</td><td>FL600<br/>7000 FT ALT</td><td>↓ ↑</td><td>Nil</td></tr>
+<tr><td>▲ HAKODATE VOR/DME (HWE)<br/>414611N 1404920E</td></tr>
+<tr><td>181°/001°</td><td>427.5</td><td>FL600<br/>FL200</td><td>↓ ↑</td><td>Nil</td></tr>
+<tr><td>△ ADDUM<br/>343852N 1395407E</td></tr>
+</tbody></table>
+<table><tbody>
+<tr><td colspan="6"><strong>Y11 (RNAV 5)</strong></td></tr>
+<tr><td>△ ADDUM<br/>343852N 1395407E</td></tr>
+<tr><td>009°</td><td>453.2</td><td>FL410<br/>FL250</td><td>↓</td><td>Nil</td></tr>
+<tr><td>▲ ABSAK<br/>421043N 1410652E</td></tr>
+</tbody></table>
+</body></html>`
+
+// mockAirspacesPage holds a FIR with a segment along a boundary, a TMA with an arc and a circular CTR.
+const mockAirspacesPage = `<html><body>
+<table>
+<thead><tr><th>Name / Lateral limits / Vertical limits / Class</th><th>Unit providing service</th><th>Callsign</th><th>Frequency</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td><td>4</td><td>5</td></tr>
+<tr>
+ <td><p>FUKUOKA FIR</p><p>450000N 1450000E - 450000N 1500000E -</p><p>along the Fukuoka/Anchorage FIR boundary to 300000N 1500000E -</p><p>300000N 1450000E - 450000N 1450000E</p><p>UNL</p><p>GND</p></td>
+ <td>Tokyo ACC</td><td>Tokyo Control</td><td>119.1MHz<br/>133.5MHz</td><td>Nil</td>
+</tr>
+<tr>
+ <td><p>TOKYO TMA</p><p>354000N 1393000E - 354000N 1400000E -</p><p>thence clockwise along the arc of a circle of 20 NM radius centred on 353312N 1394652E to 352000N 1400000E -</p><p>352000N 1393000E - 354000N 1393000E</p><p>FL140</p><p>3000FT ALT</p><p>Class C</p></td>
+ <td>Tokyo Approach</td><td>Tokyo Approach</td><td>119.1MHz</td><td>Nil</td>
+</tr>
+<tr>
+ <td><p>KOBE CTR</p><p>A circle, radius 5 NM, centred on 343800N 1351400E</p><p>3000FT ALT</p><p>SFC</p><p>Class D</p></td>
+ <td>Kobe Tower</td><td>Kobe Tower</td><td>118.15MHz</td><td>Nil</td>
+</tr>
+</tbody></table>
+</body></html>`
+
+// mockRestrictedAreasPage holds a circular prohibited area and a polygonal restricted area.
+const mockRestrictedAreasPage = `<html><body>
+<table>
+<thead><tr><th>Identification, name and lateral limits</th><th>Upper limit / Lower limit</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td></tr>
+<tr>
+ <td><p>RJP1 IMPERIAL PALACE</p><p>A circle, radius 0.5 NM, centred on 354107N 1394510E</p></td>
+ <td><p>5000FT ALT</p><p>GND</p></td>
+ <td><p>H24</p><p>Flight prohibited</p></td>
+</tr>
+<tr>
+ <td><p>RJR102</p><p>SHIMOFUSA</p><p>355000N 1400000E - 355000N 1401000E - 354000N 1401000E - 354000N 1400000E - 355000N 1400000E</p></td>
+ <td><p>FL230</p><p>3000FT ALT</p></td>
+ <td><p>Time of activity: MON-FRI 0000-0900 UTC</p><p>Military training</p></td>
+</tr>
+</tbody></table>
+</body></html>`
+
+const mockObstaclesPage = `<html><body>
+<table>
+<thead><tr><th>Obstacle identification or designation</th><th>Obstacle type</th><th>Obstacle position</th><th>Elevation</th><th>Height</th><th>Markings</th><th>Lighting</th><th>Remarks</th></tr></thead>
+<tbody>
+<tr><td>1</td><td>2</td><td>3</td><td>4</td><td>5</td><td>6</td><td>7</td><td>8</td></tr>
+<tr><td>JP0001</td><td>Tower</td><td>353236N 1394859E</td><td>2080ft</td><td>2080ft</td><td>Nil</td><td>LGTD</td><td>Tokyo Skytree</td></tr>
+<tr><td>JP0002</td><td>Chimney</td><td>343000N 1353000E</td><td>656ft</td><td>590ft</td><td>Marked</td><td>LGTD</td><td>Nil</td></tr>
+</tbody></table>
+</body></html>`
+
+// minimalPdf builds a valid one page PDF file, with a correct cross-reference table.
+func minimalPdf() []byte {
+	return pdfFile([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << >> >>",
+	})
+}
+
+// textPdf builds a PDF file with a page per list of lines, written in Helvetica.
+func textPdf(pages ...[]string) []byte {
+	//1: catalog, 2: pages, 3: font, then a page and its content per page
+	var kids []string
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", "", "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>"}
+	for _, lines := range pages {
+		pageObj := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj))
+		content := "BT /F1 12 Tf 50 800 Td 14 TL"
+		for _, l := range lines {
+			content += fmt.Sprintf(" (%s) Tj T*", l)
+		}
+		content += " ET"
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageObj+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	return pdfFile(objects)
+}
+
+// pdfFile builds a PDF file of the objects, with a correct cross-reference table.
+// The first object is the catalog.
+func pdfFile(objects []string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
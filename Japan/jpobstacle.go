@@ -0,0 +1,182 @@
+package japan
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+// GetObstacles retrieves the ENR 5.4 air navigation obstacles from the page indicated in the AIP index page.
+// The obstacles whose position cannot be converted are not kept.
+func (aipdcs *JpAipDocument) GetObstacles(cl *http.Client) ([]generic.Obstacle, error) {
+	fmt.Println("   Retrieve Air Navigation Obstacles")
+	doc, err := aipdcs.getEnrPage(cl, "ENR-5details", "ENR 5.4")
+	if err != nil {
+		return nil, fmt.Errorf("obstacle extraction: %s", err)
+	}
+
+	obstacles, problems := loadObstaclesFromSelection(doc.Selection)
+	for _, p := range problems {
+		aipdcs.AddError(fmt.Errorf("obstacle extraction: %s", p))
+	}
+	aipdcs.Obstacles = obstacles
+	fmt.Printf("Number of identified obstacles: %d \n", len(obstacles))
+	return obstacles, nil
+}
+
+// loadObstacles fills the Obstacles of the airport with the AD 2.10 section (aerodrome obstacles).
+// The obstacles without designation are identified by the ICAO code and their rank (ex: RJTT-1).
+func (apt *JpAirport) loadObstacles(doc *goquery.Document) error {
+	div, err := apt.airportSection(doc, "2.10")
+	if err != nil {
+		return fmt.Errorf("AD 2.10: %s", err)
+	}
+
+	obstacles, problems := loadObstaclesFromSelection(div)
+	for i := range obstacles {
+		obstacles[i].Airport = apt.Icao
+		if obstacles[i].Id == "" {
+			obstacles[i].Id = fmt.Sprintf("%s-%d", apt.Icao, i+1)
+		}
+	}
+	apt.Obstacles = obstacles
+	if len(problems) > 0 {
+		var msgs []string
+		for _, p := range problems {
+			msgs = append(msgs, p.Error())
+		}
+		return fmt.Errorf("AD 2.10: %s", strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// obstacleHeaders identifies the columns of an obstacle table by their header.
+// A column can give several fields (ex: Elevation/HGT, Markings/LGT).
+// The word boundaries prevent the remarks from being taken as markings.
+var obstacleHeaders = []struct {
+	field string
+	re    *regexp.Regexp
+}{
+	{"id", regexp.MustCompile(`IDENT|DESIGNATION|\bID\b`)},
+	{"area", regexp.MustCompile(`AREA|\bRWY`)},
+	{"type", regexp.MustCompile(`TYPE`)},
+	{"position", regexp.MustCompile(`COORD|POSITION`)},
+	{"elevation", regexp.MustCompile(`\bELEV`)},
+	{"height", regexp.MustCompile(`HEIGHT|\bHGT`)},
+	{"marking", regexp.MustCompile(`\bMARK`)},
+	{"lighting", regexp.MustCompile(`LIGHT|\bLGT`)},
+	{"remarks", regexp.MustCompile(`REMARK`)},
+}
+
+// loadObstaclesFromSelection retrieves the obstacles of the tables of the selection (ENR 5.4 page
+// or AD 2.10 section). The columns of each table are identified by the headers of the table.
+// The rows without coordinates are not obstacles (column numbers, "Nil").
+func loadObstaclesFromSelection(sel *goquery.Selection) ([]generic.Obstacle, []error) {
+	var obstacles []generic.Obstacle
+	var problems []error
+	sel.Find("table").Each(func(index int, table *goquery.Selection) {
+		columns := make(map[string]int)
+		table.Find("thead th").Each(func(i int, th *goquery.Selection) {
+			header := strings.ToUpper(cellText(th))
+			for _, h := range obstacleHeaders {
+				if _, ok := columns[h.field]; !ok && h.re.MatchString(header) {
+					columns[h.field] = i
+				}
+			}
+		})
+		if _, ok := columns["position"]; !ok {
+			return
+		}
+
+		table.Find("tbody tr").Each(func(index int, tr *goquery.Selection) {
+			var cells []string
+			tr.Find("td").Each(func(i int, td *goquery.Selection) {
+				cells = append(cells, cellText(td))
+			})
+			obs, ok, err := getObstacleFromCellsOfjpObstacleData(columns, cells)
+			if err != nil {
+				problems = append(problems, err)
+			}
+			if ok {
+				obstacles = append(obstacles, obs)
+			}
+		})
+	})
+	return obstacles, problems
+}
+
+// getObstacleFromCellsOfjpObstacleData converts the cells of an obstacle row.
+// The second value is false if the row is not an obstacle, or if its position cannot be converted.
+// When the elevation and the height share a column (ex: 198ft / 45m), the elevation is the first value;
+// when the markings and the lighting share a column, they are separated by a slash.
+func getObstacleFromCellsOfjpObstacleData(columns map[string]int, cells []string) (generic.Obstacle, bool, error) {
+	cell := func(field string) string {
+		if i, ok := columns[field]; ok && i < len(cells) {
+			return cells[i]
+		}
+		return ""
+	}
+
+	obs := generic.Obstacle{Id: cell("id"), Type: cell("type")}
+	if !coordinatesRe.MatchString(cell("position")) {
+		return obs, false, nil
+	}
+	pos, err := generic.ParseGeoPosition(cell("position"))
+	if err != nil {
+		return obs, false, fmt.Errorf("%s %s: %s", obs.Id, obs.Type, err)
+	}
+	obs.Position = pos
+
+	if sameColumn(columns, "elevation", "height") {
+		//Exemple: 198ft / 45m
+		measures := generic.ParseMeasures(cell("elevation"))
+		if len(measures) > 0 {
+			obs.Elevation = measures[0]
+		}
+		if len(measures) > 1 {
+			obs.Height = measures[1]
+		}
+	} else {
+		obs.Elevation, _ = generic.ParseMeasure(cell("elevation"))
+		obs.Height, _ = generic.ParseMeasure(cell("height"))
+	}
+	if ft, ok := obs.Elevation.InFeet(); ok {
+		obs.Position.Altitude = ft
+	}
+
+	if sameColumn(columns, "marking", "lighting") {
+		//Exemple: Marked / LGTD
+		parts := strings.SplitN(cell("marking"), "/", 2)
+		obs.Marking = strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			obs.Lighting = strings.TrimSpace(parts[1])
+		}
+	} else {
+		obs.Marking = cell("marking")
+		obs.Lighting = cell("lighting")
+	}
+
+	var remarks []string
+	for _, r := range []string{cell("area"), cell("remarks")} {
+		if r != "" {
+			remarks = append(remarks, r)
+		}
+	}
+	obs.Remarks = strings.Join(remarks, " ")
+
+	if obs.Elevation.Unit == "" {
+		return obs, true, fmt.Errorf("%s %s: no elevation", obs.Id, obs.Type)
+	}
+	return obs, true, nil
+}
+
+// sameColumn checks if the two fields are given by the same column.
+func sameColumn(columns map[string]int, field1 string, field2 string) bool {
+	i, ok1 := columns[field1]
+	j, ok2 := columns[field2]
+	return ok1 && ok2 && i == j
+}
@@ -0,0 +1,146 @@
+package japan
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/NagoDede/aipdownloader/generic"
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestLoadObstaclesFromSelection(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(mockObstaclesPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obstacles, problems := loadObstaclesFromSelection(doc.Selection)
+	if len(problems) != 0 {
+		t.Fatal(problems)
+	}
+	if len(obstacles) != 2 {
+		t.Fatalf("expected 2 obstacles, got %+v", obstacles)
+	}
+	tower := obstacles[0]
+	if tower.Id != "JP0001" || tower.Type != "Tower" || tower.Airport != "" || tower.Remarks != "Tokyo Skytree" {
+		t.Errorf("unexpected obstacle %+v", tower)
+	}
+	if tower.Elevation.Value != 2080 || tower.Height.Value != 2080 || tower.Position.Altitude != 2080 {
+		t.Errorf("unexpected elevation %v height %v", tower.Elevation, tower.Height)
+	}
+	if tower.Marking != "Nil" || tower.Lighting != "LGTD" {
+		t.Errorf("unexpected marking %q lighting %q", tower.Marking, tower.Lighting)
+	}
+	assertNear(t, "latitude", tower.Position.Latitude, 35+32.0/60+36.0/3600)
+	assertNear(t, "longitude", tower.Position.Longitude, 139+48.0/60+59.0/3600)
+}
+
+func TestLoadAerodromeObstacles(t *testing.T) {
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	if err := apt.loadObstacles(pageDocument(t, fmt.Sprintf(mockObstaclesSection, "RJTT", "RJTT"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(apt.Obstacles) != 2 {
+		t.Fatalf("expected 2 obstacles, got %+v", apt.Obstacles)
+	}
+	antenna := apt.Obstacles[0]
+	if antenna.Id != "RJTT-1" || antenna.Airport != "RJTT" || antenna.Type != "Antenna" {
+		t.Errorf("unexpected obstacle %+v", antenna)
+	}
+	//the elevation and the height share a column, as the markings and the lighting
+	if antenna.Elevation.Value != 198 || antenna.Elevation.Unit != "FT" || antenna.Height.Value != 45 || antenna.Height.Unit != "M" {
+		t.Errorf("unexpected elevation %v height %v", antenna.Elevation, antenna.Height)
+	}
+	if antenna.Marking != "Marked" || antenna.Lighting != "LGTD" || antenna.Remarks != "RWY 34L APCH Nil" {
+		t.Errorf("unexpected marking %q lighting %q remarks %q", antenna.Marking, antenna.Lighting, antenna.Remarks)
+	}
+	assertNear(t, "latitude", antenna.Position.Latitude, 35+31.0/60+30.0/3600)
+}
+
+func TestObstaclesWithin(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(mockObstaclesPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obstacles, _ := loadObstaclesFromSelection(doc.Selection)
+	apt := &JpAirport{}
+	apt.Icao = "RJTT"
+	if err := apt.loadObstacles(pageDocument(t, fmt.Sprintf(mockObstaclesSection, "RJTT", "RJTT"))); err != nil {
+		t.Fatal(err)
+	}
+	obstacles = append(obstacles, apt.Obstacles...)
+
+	arp := generic.GeoPosition{Latitude: 35 + 33.0/60 + 12.0/3600, Longitude: 139 + 46.0/60 + 52.0/3600}
+	near := generic.ObstaclesWithin(obstacles, arp, 3)
+	if len(near) != 3 {
+		t.Fatalf("expected 3 obstacles within 3 NM, got %+v", near)
+	}
+	for i := 1; i < len(near); i++ {
+		if arp.DistanceTo(near[i-1].Position) > arp.DistanceTo(near[i].Position) {
+			t.Errorf("obstacles not sorted by distance: %s before %s", near[i-1].Id, near[i].Id)
+		}
+	}
+	if len(generic.ObstaclesWithin(obstacles, arp, 0.5)) != 0 {
+		t.Error("no obstacle expected within 0.5 NM")
+	}
+}
+
+func TestExportObstacles(t *testing.T) {
+	doc := &JpAipDocument{}
+	doc.CountryCode = "Japan"
+	page, err := goquery.NewDocumentFromReader(strings.NewReader(mockObstaclesPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Obstacles, _ = loadObstaclesFromSelection(page.Selection)
+	doc.Airports = make([]JpAirport, 1)
+	apt := &doc.Airports[0]
+	apt.Icao = "RJTT"
+	if err := apt.loadAdminData(pageDocument(t, fmt.Sprintf(mockAdminDataSection, "RJTT", "RJTT"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := apt.loadObstacles(pageDocument(t, fmt.Sprintf(mockObstaclesSection, "RJTT", "RJTT"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := generic.ExportGeoJson(doc, &b); err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(b.Bytes(), &collection); err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]int)
+	for _, f := range collection.Features {
+		kinds[f.Properties["kind"].(string)]++
+		if f.Properties["id"] == "JP0001" && (f.Geometry.Type != "Point" || f.Geometry.Coordinates[0] < 139 || f.Geometry.Coordinates[1] > 36) {
+			t.Errorf("unexpected JP0001 geometry %+v", f.Geometry)
+		}
+	}
+	//the national and the aerodrome obstacles
+	if kinds["obstacle"] != 4 || kinds["airport"] != 1 {
+		t.Errorf("unexpected features %v", kinds)
+	}
+
+	b.Reset()
+	if err := generic.ExportKml(doc, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<name>obstacle</name>") || !strings.Contains(b.String(), "<name>JP0001</name>") {
+		t.Errorf("obstacles not exported in KML:\n%s", b.String())
+	}
+}
@@ -2,6 +2,7 @@ package japan
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,67 +13,63 @@ import (
 	"github.com/NagoDede/aipdownloader/writerseeker"
 )
 
+// MergePdfDataOfAiport produces the merged files of the airport, one file per merge profile
+// of the configuration (see generic.MergeProfile).
+// A profile which selects no file of the airport produces no file. A profile which selects
+// a single file goes through the writer as well, so the file gets its outline and is only
+// rewritten when needed.
+// The outline of a merged file gives a bookmark per source file (see mergeOutline).
+// The merged files are recorded in the airport once all the profiles are produced,
+// so a new attempt does not record them twice.
 func MergePdfDataOfAiport(apt *generic.Airport) error {
-	var outPath string
-	pdfWriter := pdf.NewPdfWriter()
 	pdf.SetPdfCreationDate(time.Now())
 	pdf.SetPdfAuthor("Nagoy Dede")
 	pdf.SetPdfKeywords(apt.Icao + " AIP Japan")
 	pdf.SetPdfProducer("AipDownloader")
 
-	outPath = apt.AipDocument.DirMergeFiles()
+	outPath := apt.AipDocument.DirMergeFiles()
 
 	//create the directory
 	os.MkdirAll(outPath, os.ModePerm)
 
-	outFullMerge := generic.MergedData{FileName: apt.Icao + "_full.pdf", FileDirectory: outPath}
-	apt.MergePdf = append(apt.MergePdf, outFullMerge)
-	outChartMerge := generic.MergedData{FileName: apt.Icao + "_chart.pdf", FileDirectory: outPath}
-	apt.MergePdf = append(apt.MergePdf, outChartMerge)
-
-	outFullPath := filepath.Join(outPath, apt.Icao+"_full.pdf")
-	outChartPath := filepath.Join(outPath, apt.Icao+"_chart.pdf")
-
-	//First create the Charts merge file
-	pdf.SetPdfTitle(apt.Icao + "AIP charts ")
-	pdf.SetPdfSubject(apt.Icao + " merged charts")
-	for _, pdfD := range apt.PdfData[1:] {
-		err := mergeInPdfWriter(&pdfWriter, &pdfD)
-		if err != nil {
-			return err
+	var merged []generic.MergedData
+	for _, profile := range generic.ConfData.MergeProfileList() {
+		selected := profile.Select(apt.PdfData)
+		if len(selected) == 0 {
+			log.Printf("Airport %s - no file for the merge profile %s \n", apt.Icao, profile.Name)
+			continue
 		}
-	}
-
-	if shouldUpdateMergePdfFile(apt, outChartPath, &pdfWriter) {
-		err2 := writePdfWriter(&pdfWriter, outChartPath)
-		if err2 != nil {
-			return err2
-		}
-	}
-
-	//create the full merge
-	pdf.SetPdfTitle(apt.Icao + " AIP document")
-	pdf.SetPdfSubject(apt.Icao + " merged AIP document")
-	pdfFullWriter := pdf.NewPdfWriter()
-	for _, pdfD := range apt.PdfData {
-		err := mergeInPdfWriter(&pdfFullWriter, &pdfD)
-		if err != nil {
-			return err
+		out := generic.MergedData{Title: profile.Name, FileName: profile.OutputName(apt.Icao), FileDirectory: outPath}
+		outFilePath := filepath.Join(out.FileDirectory, out.FileName)
+
+		pdf.SetPdfTitle(apt.Icao + " AIP " + profile.Name)
+		pdf.SetPdfSubject(apt.Icao + " merged AIP " + profile.Name)
+		pdfWriter := pdf.NewPdfWriter()
+		outline := newMergeOutline(apt.Icao)
+		for _, pdfD := range selected {
+			err := mergeInPdfWriter(&pdfWriter, &pdfD, outline)
+			if err != nil {
+				return err
+			}
 		}
-	}
+		pdfWriter.AddOutlineTree(outline.tree())
 
-	if shouldUpdateMergePdfFile(apt, outFullPath, &pdfFullWriter) {
-		err2 := writePdfWriter(&pdfFullWriter, outFullPath)
-		if err2 != nil {
-			return err2
+		if shouldUpdateMergePdfFile(apt, outFilePath, &pdfWriter) {
+			err2 := writePdfWriter(&pdfWriter, outFilePath)
+			if err2 != nil {
+				return err2
+			}
 		}
+		merged = append(merged, out)
 	}
 
+	apt.MergePdf = merged
 	return nil
 
 }
 
-func mergeInPdfWriter(pdfWriter *pdf.PdfWriter, pdfD *generic.PdfData) error {
+// mergeInPdfWriter adds the pages of the file to the writer, and its bookmark to the outline.
+func mergeInPdfWriter(pdfWriter *pdf.PdfWriter, pdfD *generic.PdfData, outline *mergeOutline) error {
 	inPath := pdfD.FilePath
 	f, err := os.Open(inPath)
 	if err != nil {
@@ -94,12 +91,13 @@ func mergeInPdfWriter(pdfWriter *pdf.PdfWriter, pdfD *generic.PdfData) error {
 		return fmt.Errorf("Error when retrieving the number of pages of the file " + inPath)
 	}
 
+	var pages []*pdf.PdfPage
 	for i := 0; i < numPages; i++ {
 		pageNum := i + 1
 
 		page, err4 := pdfReader.GetPage(pageNum)
 		if err4 != nil {
-			log.Println("Error while retrieving the page %d of file %s", pageNum, inPath)
+			log.Printf("Error while retrieving the page %d of file %s \n", pageNum, inPath)
 			return fmt.Errorf("Error while retrieving the page %d of file %s", pageNum, inPath)
 		}
 
@@ -108,23 +106,21 @@ func mergeInPdfWriter(pdfWriter *pdf.PdfWriter, pdfD *generic.PdfData) error {
 			log.Println("Error during  pdfWriter.AddPage(page)" + inPath)
 			return fmt.Errorf("Error while adding page " + inPath)
 		}
+		pages = append(pages, page)
 	}
+	outline.add(pdfD, pages)
 	return nil
 }
 
+// writePdfWriter writes the merged file atomically: the file is written in a temporary
+// file renamed as outPath once complete, so an interrupted merge never leaves a truncated file.
 func writePdfWriter(pdfWriter *pdf.PdfWriter, outPath string) error {
-	fWrite, err := os.Create(outPath)
-	if err != nil {
-		log.Println("Error during  os.Create(outPath)" + outPath)
-		return fmt.Errorf("Error during  pdf creation of file " + outPath)
-	}
-
-	defer fWrite.Close()
-
-	err = pdfWriter.Write(fWrite)
+	err := generic.WriteFileAtomic(outPath, func(w io.Writer) error {
+		return pdfWriter.Write(w)
+	}, nil)
 	if err != nil {
 		log.Println("Error during  pdfWriter.Write(fWrite)" + outPath)
-		return fmt.Errorf("Error during pdf writing " + outPath)
+		return fmt.Errorf("Error during pdf writing %s: %s", outPath, err)
 	}
 
 	return nil
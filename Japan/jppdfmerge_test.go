@@ -0,0 +1,51 @@
+package japan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+func TestMergePdfDataOfAiportTwice(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := generic.ConfData
+	t.Cleanup(func() {
+		generic.ConfData = saved
+		os.RemoveAll(dir)
+	})
+	generic.ConfData = generic.ConfigurationDataStruct{MainLocalDir: dir, MergeDir: "merge"}
+
+	doc := &JpAipDocument{}
+	doc.CountryCode = "Japan"
+	doc.EffectiveDate = time.Now().AddDate(0, 0, -1)
+	doc.NextEffectiveDate = time.Now().AddDate(0, 0, 27)
+	apt := &generic.Airport{Icao: "RJTT", AipDocument: doc}
+	for _, p := range []generic.PdfData{
+		{DataContentType: "Text", FileName: "text.pdf"},
+		{DataContentType: "Chart", Category: generic.ChartADC, FileName: "adc.pdf"},
+	} {
+		p.FilePath = filepath.Join(dir, p.FileName)
+		if err := ioutil.WriteFile(p.FilePath, minimalPdf(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		apt.PdfData = append(apt.PdfData, p)
+	}
+
+	//a new attempt replaces the merged files of the previous one
+	for i := 0; i < 2; i++ {
+		if err := MergePdfDataOfAiport(apt); err != nil {
+			t.Fatal(err)
+		}
+		if len(apt.MergePdf) != 2 {
+			t.Fatalf("attempt %d: expected 2 merged files, got %+v", i+1, apt.MergePdf)
+		}
+	}
+	assertFilesExist(t, filepath.Join(dir, "Japan", doc.EffectiveDate.Format("20060102")), "merge/RJTT_full.pdf", "merge/RJTT_chart.pdf")
+}
@@ -0,0 +1,187 @@
+package japan
+
+import (
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/NagoDede/aipdownloader/generic"
+	"github.com/NagoDede/unipdf/contentstream"
+	"github.com/NagoDede/unipdf/core"
+	pdf "github.com/NagoDede/unipdf/model"
+)
+
+// mergeOutline builds the outline (bookmarks) of a merged file while its source files are merged.
+// Each source file is a bookmark. The charts are grouped by category when known,
+// the text of the airport part is broken down per AD 2.x (or AD 3.x) subsection when found in the text.
+type mergeOutline struct {
+	icao    string
+	outline *pdf.Outline
+	groups  map[string]*pdf.OutlineItem
+	pages   int64
+}
+
+func newMergeOutline(icao string) *mergeOutline {
+	return &mergeOutline{icao: icao, outline: pdf.NewOutline(), groups: make(map[string]*pdf.OutlineItem)}
+}
+
+// add records the bookmark of a source file whose pages follow the pages already merged.
+func (o *mergeOutline) add(pdfD *generic.PdfData, pages []*pdf.PdfPage) {
+	if len(pages) == 0 {
+		return
+	}
+	title := pdfD.Title
+	if title == "" {
+		title = pdfD.FileName
+	}
+	item := pdf.NewOutlineItem(title, o.dest(0, pages))
+	if pdfD.DataContentType == "Text" {
+		for _, s := range o.textSections(pages) {
+			item.Add(pdf.NewOutlineItem(s.title, o.dest(s.page, pages)))
+		}
+	}
+
+	switch pdfD.Category {
+	case "", generic.ChartOther:
+		o.outline.Add(item)
+	default:
+		group, ok := o.groups[pdfD.Category]
+		if !ok {
+			group = pdf.NewOutlineItem(generic.ChartCategoryTitle(pdfD.Category), o.dest(0, pages))
+			o.groups[pdfD.Category] = group
+			o.outline.Add(group)
+		}
+		group.Add(item)
+	}
+	o.pages += int64(len(pages))
+}
+
+// dest provides the destination of the top of the indicated page of the source file.
+func (o *mergeOutline) dest(page int, pages []*pdf.PdfPage) pdf.OutlineDest {
+	var top float64
+	if box, err := pages[page].GetMediaBox(); err == nil {
+		top = box.Ury
+	}
+	return pdf.NewOutlineDest(o.pages+int64(page), 0, top)
+}
+
+// outlineSection is a subsection of the text of the airport part, starting at the indicated page.
+type outlineSection struct {
+	title string
+	page  int
+}
+
+// textSections identifies the subsections of the text of the airport part by their heading
+// (ex: RJTT AD 2.12 RUNWAY PHYSICAL CHARACTERISTICS). A subsection starts at the first page
+// where its heading appears; the headings are in increasing order, so the references to
+// a previous subsection are not headings.
+// The pages whose text cannot be extracted are ignored.
+func (o *mergeOutline) textSections(pages []*pdf.PdfPage) []outlineSection {
+	headingRe := regexp.MustCompile(`(?m)\b` + regexp.QuoteMeta(o.icao) + `\s+AD\s+[23]\.([0-9]{1,2})\b.*$`)
+	var sections []outlineSection
+	last := 0
+	for i, page := range pages {
+		text, err := pageText(page)
+		if err != nil {
+			log.Printf("Airport %s - page %d: %s \n", o.icao, i+1, err)
+			continue
+		}
+		for _, m := range headingRe.FindAllStringSubmatch(text, -1) {
+			num, _ := strconv.Atoi(m[1])
+			if num > last {
+				last = num
+				sections = append(sections, outlineSection{title: strings.Join(strings.Fields(m[0]), " "), page: i})
+			}
+		}
+	}
+	return sections
+}
+
+// pageText provides the text shown by the page, a line per line of the page.
+// The text is read from the content of the page rather than with the extractor of unipdf,
+// which truncates the text without license.
+func pageText(page *pdf.PdfPage) (string, error) {
+	content, err := page.GetAllContentStreams()
+	if err != nil {
+		return "", err
+	}
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	var font *pdf.PdfFont
+	fonts := make(map[core.PdfObjectName]*pdf.PdfFont)
+	show := func(obj core.PdfObject) {
+		s, ok := core.GetString(obj)
+		if !ok {
+			return
+		}
+		if font == nil {
+			b.WriteString(s.Str())
+			return
+		}
+		t, _, _ := font.CharcodeBytesToUnicode(s.Bytes())
+		b.WriteString(t)
+	}
+
+	for _, op := range *ops {
+		switch op.Operand {
+		case "Tf":
+			if len(op.Params) == 0 {
+				continue
+			}
+			name, _ := core.GetName(op.Params[0])
+			if name == nil {
+				continue
+			}
+			f, ok := fonts[*name]
+			if !ok && page.Resources != nil {
+				if obj, found := page.Resources.GetFontByName(*name); found {
+					f, _ = pdf.NewPdfFontFromPdfObject(obj)
+				}
+				fonts[*name] = f
+			}
+			font = f
+		case "Tj":
+			if len(op.Params) > 0 {
+				show(op.Params[0])
+			}
+		case "'", "\"":
+			b.WriteString("\n")
+			if len(op.Params) > 0 {
+				show(op.Params[len(op.Params)-1])
+			}
+		case "TJ":
+			if len(op.Params) == 0 {
+				continue
+			}
+			if arr, ok := core.GetArray(op.Params[0]); ok {
+				for _, e := range arr.Elements() {
+					show(e)
+				}
+			}
+		case "T*":
+			b.WriteString("\n")
+		case "Td", "TD":
+			//a move on the same line separates words
+			if len(op.Params) == 2 {
+				if ty, err := core.GetNumberAsFloat(op.Params[1]); err == nil && ty != 0 {
+					b.WriteString("\n")
+					continue
+				}
+			}
+			b.WriteString(" ")
+		case "Tm", "ET":
+			b.WriteString(" ")
+		}
+	}
+	return b.String(), nil
+}
+
+// tree provides the outline tree to add to the writer of the merged file.
+func (o *mergeOutline) tree() *pdf.PdfOutlineTreeNode {
+	return &o.outline.ToPdfOutline().PdfOutlineTreeNode
+}
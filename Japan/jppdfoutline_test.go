@@ -0,0 +1,127 @@
+package japan
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NagoDede/aipdownloader/generic"
+	"github.com/NagoDede/unipdf/core"
+	pdf "github.com/NagoDede/unipdf/model"
+)
+
+func TestMergeOutline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	text := textPdf(
+		[]string{"RJTT AD 2.1 AERODROME LOCATION INDICATOR AND NAME", "RJTT AD 2.2 AERODROME GEOGRAPHICAL AND ADMINISTRATIVE DATA"},
+		[]string{"see RJTT AD 2.1", "RJTT AD 2.12 RUNWAY PHYSICAL CHARACTERISTICS"},
+	)
+	pdfs := []generic.PdfData{
+		{DataContentType: "Text", Title: "RJTT AD 2", FileName: "text.pdf"},
+		{DataContentType: "Chart", Category: generic.ChartIAC, Title: "ILS Z RWY34R", FileName: "iac1.pdf"},
+		{DataContentType: "Chart", Category: generic.ChartADC, Title: "AERODROME CHART", FileName: "adc.pdf"},
+		{DataContentType: "Chart", Category: generic.ChartIAC, Title: "RNP RWY16L", FileName: "iac2.pdf"},
+		{DataContentType: "Chart", Category: generic.ChartOther, FileName: "other.pdf"},
+	}
+	pdfWriter := pdf.NewPdfWriter()
+	outline := newMergeOutline("RJTT")
+	for i := range pdfs {
+		content := minimalPdf()
+		if pdfs[i].DataContentType == "Text" {
+			content = text
+		}
+		pdfs[i].FilePath = filepath.Join(dir, pdfs[i].FileName)
+		if err := ioutil.WriteFile(pdfs[i].FilePath, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := mergeInPdfWriter(&pdfWriter, &pdfs[i], outline); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pdfWriter.AddOutlineTree(outline.tree())
+	out := filepath.Join(dir, "RJTT_full.pdf")
+	if err := writePdfWriter(&pdfWriter, out); err != nil {
+		t.Fatal(err)
+	}
+
+	pages, titles := readOutline(t, out)
+	if pages != 6 {
+		t.Errorf("expected 6 pages, got %d", pages)
+	}
+
+	//the reference to AD 2.1 on the second page is not a subsection,
+	//the charts are grouped by category in the order of their first chart
+	expected := []string{
+		"RJTT AD 2 p0",
+		"-RJTT AD 2.1 AERODROME LOCATION INDICATOR AND NAME p0",
+		"-RJTT AD 2.2 AERODROME GEOGRAPHICAL AND ADMINISTRATIVE DATA p0",
+		"-RJTT AD 2.12 RUNWAY PHYSICAL CHARACTERISTICS p1",
+		"Instrument Approach Charts p2",
+		"-ILS Z RWY34R p2",
+		"-RNP RWY16L p4",
+		"Aerodrome Charts p3",
+		"-AERODROME CHART p3",
+		"other.pdf p5",
+	}
+	if strings.Join(titles, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected outline:\n%s", strings.Join(titles, "\n"))
+	}
+}
+
+// readOutline provides the number of pages of the PDF file and the titles of its outline,
+// indented by level, with the index of their page (ex: -ILS Z RWY34R p2).
+func readOutline(t *testing.T, path string) (int, []string) {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	reader, err := pdf.NewPdfReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := reader.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the items of the outline are read as dictionaries: Title, First child and Next sibling
+	var titles []string
+	var walk func(obj core.PdfObject, depth int)
+	walk = func(obj core.PdfObject, depth int) {
+		for obj != nil {
+			dict, ok := core.GetDict(obj)
+			if !ok {
+				return
+			}
+			title, _ := core.GetString(dict.Get("Title"))
+			page := -1
+			if dest, ok := core.GetArray(dict.Get("Dest")); ok && dest.Len() > 0 {
+				page, _ = core.GetIntVal(dest.Get(0))
+			}
+			titles = append(titles, fmt.Sprintf("%s%s p%d", strings.Repeat("-", depth), title.Str(), page))
+			walk(dict.Get("First"), depth+1)
+			obj = dict.Get("Next")
+		}
+	}
+	trailer, err := reader.GetTrailer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, _ := core.GetDict(trailer.Get("Root"))
+	root, ok := core.GetDict(catalog.Get("Outlines"))
+	if !ok {
+		t.Fatalf("no outline in %s", path)
+	}
+	walk(root.Get("First"), 0)
+	return pages, titles
+}
@@ -0,0 +1,481 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NagoDede/aipdownloader/generic"
+)
+
+/*
+ A command is a stage of the pipeline which can be run from the command line.
+ The run function is called for each selected country, once its configuration file is loaded.
+ It returns an error when the command cannot be run, and the failures of the individual
+ airports and files in a report.
+*/
+type command struct {
+	name        string
+	description string
+	flags       *flag.FlagSet
+	run         func(ais generic.CountryAis) (*generic.FailureReport, error)
+}
+
+// Global flags, shared by all the commands.
+type globalOptions struct {
+	configPath        string
+	dataDir           string
+	country           string
+	countryConfigPath string
+	maxFailures       int
+	downloadWorkers   int
+	pageWorkers       int
+	requestsPerSecond float64
+	recordDir         string
+	replayDir         string
+}
+
+var (
+	opts globalOptions
+
+	downloadMerge  bool
+	exportFormat   string
+	exportPath     string
+	routeFrom      string
+	routeTo        string
+	routeMinLevel  int
+	routeMaxLevel  int
+	obstacleNear   string
+	obstacleRadius float64
+)
+
+func commands() []*command {
+	download := flag.NewFlagSet("download", flag.ExitOnError)
+	download.BoolVar(&downloadMerge, "merge", false, "merge the files of each airport once downloaded")
+
+	export := flag.NewFlagSet("export", flag.ExitOnError)
+	export.StringVar(&exportFormat, "format", "json", "export format ("+strings.Join(generic.ExportFormats(), ", ")+")")
+	export.StringVar(&exportPath, "out", "", "output file (default: export.<format> in the edition directory)")
+
+	route := flag.NewFlagSet("route", flag.ExitOnError)
+	route.StringVar(&routeFrom, "from", "", "identifier of the departure point (navaid or waypoint)")
+	route.StringVar(&routeTo, "to", "", "identifier of the destination point (navaid or waypoint)")
+	route.IntVar(&routeMinLevel, "minfl", 0, "lowest usable flight level")
+	route.IntVar(&routeMaxLevel, "maxfl", 999, "highest usable flight level")
+
+	obstacles := flag.NewFlagSet("obstacles", flag.ExitOnError)
+	obstacles.StringVar(&obstacleNear, "near", "", "centre of the search: ICAO code of an airport or coordinates (ex: \"353312N 1394652E\")")
+	obstacles.Float64Var(&obstacleRadius, "radius", 5, "radius of the search in nautical miles")
+
+	return []*command{
+		{name: "process", description: "download and merge all the data of the active edition (default)", run: runProcess},
+		{name: "editions", description: "list the editions published in the AIP", run: runEditions},
+		{name: "download", description: "download the airports data of the active edition", flags: download, run: runDownload},
+		{name: "merge", description: "merge the downloaded files of each airport", run: runMerge},
+		{name: "navaids", description: "list the navaids of the active edition", run: runNavaids},
+		{name: "waypoints", description: "list the waypoints of the active edition", run: runWaypoints},
+		{name: "route", description: "find the shortest airway path between two points", flags: route, run: runRoute},
+		{name: "obstacles", description: "list the obstacles around an airport or a position", flags: obstacles, run: runObstacles},
+		{name: "export", description: "export the active edition", flags: export, run: runExport},
+		{name: "verify", description: "verify the downloaded and merged files of the active edition", run: runVerify},
+	}
+}
+
+/*
+	Parse the command line and run the selected command for the selected countries.
+	Syntax: aipdownloader [global flags] [command] [command flags]
+*/
+func runCli(args []string) error {
+	cmds := commands()
+
+	global := flag.NewFlagSet("aipdownloader", flag.ExitOnError)
+	global.StringVar(&opts.configPath, "config", "./aipdownloader.json", "main configuration file")
+	global.StringVar(&opts.dataDir, "datadir", "", "directory where the data are stored (overrides mainLocalDir)")
+	global.StringVar(&opts.country, "country", "", "process only this country (default: all the configured countries)")
+	global.StringVar(&opts.countryConfigPath, "countryconfig", "", "configuration file of the country selected by -country")
+	global.IntVar(&opts.maxFailures, "maxfailures", 0, "number of failures accepted before exiting with an error, negative for no limit (overrides maxFailures)")
+	global.IntVar(&opts.downloadWorkers, "workers", 0, "number of concurrent PDF downloads (overrides downloadWorkers)")
+	global.IntVar(&opts.pageWorkers, "pageworkers", 0, "number of airport pages retrieved concurrently (overrides pageWorkers)")
+	global.Float64Var(&opts.requestsPerSecond, "rps", 0, "maximum number of requests per second and per host, 0 for no limit (overrides requestsPerSecond)")
+	global.StringVar(&opts.recordDir, "record", "", "record the HTTP exchanges of the run in this directory")
+	global.StringVar(&opts.replayDir, "replay", "", "replay the HTTP exchanges recorded in this directory, without network access")
+	global.Usage = func() { usage(global, cmds) }
+	global.Parse(args)
+
+	cmd := cmds[0]
+	if global.NArg() > 0 {
+		cmd = findCommand(cmds, global.Arg(0))
+		if cmd == nil {
+			usage(global, cmds)
+			return fmt.Errorf("unknown command %s", global.Arg(0))
+		}
+		if cmd.flags != nil {
+			cmd.flags.Parse(global.Args()[1:])
+		}
+	}
+
+	if err := generic.ConfData.LoadConfigurationFile(opts.configPath); err != nil {
+		return err
+	}
+	if isFlagSet(global, "maxfailures") {
+		generic.ConfData.MaxFailures = opts.maxFailures
+	}
+	if opts.dataDir != "" {
+		generic.ConfData.MainLocalDir = opts.dataDir
+	}
+	if isFlagSet(global, "workers") {
+		generic.ConfData.DownloadWorkers = opts.downloadWorkers
+	}
+	if isFlagSet(global, "pageworkers") {
+		generic.ConfData.PageWorkers = opts.pageWorkers
+	}
+	if isFlagSet(global, "rps") {
+		generic.ConfData.RequestsPerSecond = opts.requestsPerSecond
+	}
+	if opts.recordDir != "" && opts.replayDir != "" {
+		return errors.New("-record and -replay cannot be used together")
+	} else if opts.recordDir != "" {
+		generic.ConfData.HttpMode, generic.ConfData.HttpArchiveDir = generic.HttpModeRecord, opts.recordDir
+	} else if opts.replayDir != "" {
+		generic.ConfData.HttpMode, generic.ConfData.HttpArchiveDir = generic.HttpModeReplay, opts.replayDir
+	}
+	if err := generic.ConfData.CheckHttpMode(); err != nil {
+		return err
+	}
+	fmt.Printf("Data will be stored in %s \n", generic.ConfData.MainLocalDir)
+
+	countries, err := selectedCountries()
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	report := &generic.FailureReport{}
+	for _, country := range countries {
+		ais, err := generic.GetCountry(country.Name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s %s with %s \n", cmd.name, country.Name, country.ConfigFile)
+		if err := ais.LoadJsonFile(country.ConfigFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s \n", country.Name, err)
+			failed = append(failed, country.Name)
+			continue
+		}
+		countryReport, err := cmd.run(ais)
+		report.Merge(countryReport)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s \n", country.Name, err)
+			failed = append(failed, country.Name)
+		}
+	}
+
+	report.Print(os.Stdout)
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed for %s", cmd.name, strings.Join(failed, ", "))
+	}
+	if report.ExceedsThreshold(generic.ConfData.MaxFailures) {
+		return fmt.Errorf("%d failure(s), more than the %d accepted", report.Count(), generic.ConfData.MaxFailures)
+	}
+	return nil
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func findCommand(cmds []*command, name string) *command {
+	for _, c := range cmds {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func usage(global *flag.FlagSet, cmds []*command) {
+	out := global.Output()
+	fmt.Fprintln(out, "Usage: aipdownloader [global flags] [command] [command flags]")
+	fmt.Fprintln(out, "Global flags:")
+	global.PrintDefaults()
+	fmt.Fprintln(out, "Commands:")
+	for _, c := range cmds {
+		fmt.Fprintf(out, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintln(out, "Use aipdownloader <command> -h for the flags of a command.")
+}
+
+/*
+	Determine the countries to process.
+	Without -country, all the countries of the configuration file are processed.
+	With -country, only this country is processed; its configuration file is
+	given by -countryconfig, else by the configuration file.
+*/
+func selectedCountries() ([]generic.CountryConfig, error) {
+	if opts.country == "" {
+		if opts.countryConfigPath != "" {
+			return nil, errors.New("-countryconfig requires -country")
+		}
+		if len(generic.ConfData.Countries) == 0 {
+			return nil, fmt.Errorf("no country defined in %s", opts.configPath)
+		}
+		return generic.ConfData.Countries, nil
+	}
+
+	selected := generic.CountryConfig{Name: opts.country, ConfigFile: opts.countryConfigPath}
+	if selected.ConfigFile == "" {
+		for _, c := range generic.ConfData.Countries {
+			if strings.EqualFold(c.Name, opts.country) {
+				selected.ConfigFile = c.ConfigFile
+			}
+		}
+	}
+	if selected.ConfigFile == "" {
+		return nil, fmt.Errorf("no configuration file for %s, use -countryconfig", opts.country)
+	}
+	return []generic.CountryConfig{selected}, nil
+}
+
+func runProcess(ais generic.CountryAis) (*generic.FailureReport, error) {
+	return ais.Process()
+}
+
+func runEditions(ais generic.CountryAis) (*generic.FailureReport, error) {
+	client, err := ais.InitClient()
+	if err != nil {
+		return nil, err
+	}
+	editions, err := ais.Editions(&client)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range editions {
+		active := " "
+		if d.IsActive {
+			active = "*"
+		}
+		fmt.Printf("%s effective %s published %s valid %t %s \n", active,
+			d.EffectiveDate.Format("02-Jan-2006"), d.PublicationDate.Format("02-Jan-2006"),
+			d.IsValidDate && d.IsPartialURLValid, d.FullURLPage)
+	}
+	return nil, nil
+}
+
+// activeDocument logs in and retrieves the active edition.
+func activeDocument(ais generic.CountryAis) (generic.IAipDocument, *http.Client, error) {
+	client, err := ais.InitClient()
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := ais.ActiveDocument(&client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, &client, nil
+}
+
+// loadActiveDocument logs in, retrieves the active edition and its airports.
+func loadActiveDocument(ais generic.CountryAis) (generic.IAipDocument, *http.Client, error) {
+	doc, client, err := activeDocument(ais)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Println("Retrieve the Airports List")
+	if err := doc.LoadAirports(client); err != nil {
+		return nil, nil, err
+	}
+	return doc, client, nil
+}
+
+func runDownload(ais generic.CountryAis) (*generic.FailureReport, error) {
+	doc, client, err := loadActiveDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	doc.DownloadAllAiportsData(client, downloadMerge)
+	if err := generic.WriteReport(doc); err != nil {
+		return generic.CollectFailures(doc), err
+	}
+	if err := generic.WriteChartCatalog(doc); err != nil {
+		return generic.CollectFailures(doc), err
+	}
+	return generic.CollectFailures(doc), nil
+}
+
+func runMerge(ais generic.CountryAis) (*generic.FailureReport, error) {
+	doc, _, err := loadActiveDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	doc.MergeAllAiportsData()
+	return generic.CollectFailures(doc), nil
+}
+
+func runNavaids(ais generic.CountryAis) (*generic.FailureReport, error) {
+	doc, client, err := activeDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	navaids, err := doc.GetNavaids(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range navaids {
+		fmt.Printf("%-6s %-12s %-10s %s (%f, %f) \n", n.Id, n.NavaidType, n.Frequency, n.Name,
+			n.Position.Latitude, n.Position.Longitude)
+	}
+	fmt.Printf("%d navaids \n", len(navaids))
+	return nil, nil
+}
+
+func runWaypoints(ais generic.CountryAis) (*generic.FailureReport, error) {
+	doc, client, err := activeDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	waypoints, err := doc.GetWaypoints(client)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range waypoints {
+		fmt.Printf("%-6s (%f, %f) %s \n", w.Id, w.Position.Latitude, w.Position.Longitude,
+			strings.Join(w.Routes, " "))
+	}
+	fmt.Printf("%d waypoints \n", len(waypoints))
+	return nil, nil
+}
+
+func runRoute(ais generic.CountryAis) (*generic.FailureReport, error) {
+	if routeFrom == "" || routeTo == "" {
+		return nil, errors.New("route requires -from and -to")
+	}
+	doc, client, err := activeDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := doc.GetRoutes(client); err != nil {
+		return nil, err
+	}
+	//the segments with an unknown point are not in the graph, they are reported by GetRoutes
+	//so the report explains a missing route
+	graph, _ := generic.RouteGraphOf(doc)
+	legs, err := graph.ShortestPath(strings.ToUpper(routeFrom), strings.ToUpper(routeTo), routeMinLevel, routeMaxLevel)
+	if err != nil {
+		return generic.CollectFailures(doc), err
+	}
+
+	var total float32
+	for _, l := range legs {
+		total += l.Distance
+		fmt.Printf("%-6s %-6s -> %-6s %7.1f NM \n", l.Route, l.From.Id, l.To.Id, l.Distance)
+	}
+	fmt.Printf("%d legs, total %.1f NM \n", len(legs), total)
+	return generic.CollectFailures(doc), nil
+}
+
+func runObstacles(ais generic.CountryAis) (*generic.FailureReport, error) {
+	if obstacleNear == "" {
+		return nil, errors.New("obstacles requires -near")
+	}
+	doc, client, err := loadActiveDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := doc.GetObstacles(client); err != nil {
+		doc.AddError(err)
+	}
+	centre, err := searchCentre(doc, obstacleNear)
+	if err != nil {
+		return nil, err
+	}
+
+	near := generic.ObstaclesWithin(generic.ObstaclesOf(doc), centre, float32(obstacleRadius))
+	for _, o := range near {
+		fmt.Printf("%-10s %-12s %6.0f %-2s %5.1f NM %s %s \n", o.Id, o.Type, o.Elevation.Value, o.Elevation.Unit,
+			centre.DistanceTo(o.Position), o.Marking, o.Lighting)
+	}
+	fmt.Printf("%d obstacles within %.1f NM \n", len(near), obstacleRadius)
+	return generic.CollectFailures(doc), nil
+}
+
+// searchCentre provides the position of the airport with the indicated ICAO code,
+// else the position given by the coordinates of the text.
+// An airport whose ARP is unknown gives an error rather than a search around 0N 0E.
+func searchCentre(doc generic.IAipDocument, near string) (generic.GeoPosition, error) {
+	for _, apt := range doc.AirportsList() {
+		if strings.EqualFold(apt.Icao, near) {
+			arp := apt.AdminData.ArpCoord
+			if arp.Latitude == 0 && arp.Longitude == 0 {
+				return generic.GeoPosition{}, fmt.Errorf("ARP of %s unknown", apt.Icao)
+			}
+			return arp, nil
+		}
+	}
+	pos, err := generic.ParseGeoPosition(near)
+	if err != nil {
+		return generic.GeoPosition{}, fmt.Errorf("unknown airport or position %s", near)
+	}
+	return pos, nil
+}
+
+// loadEnrouteData retrieves the en-route data of the document: navaids, waypoints, routes, airspaces,
+// prohibited, restricted and danger areas and obstacles.
+// The errors are recorded in the document, so they are part of the failure report.
+func loadEnrouteData(doc generic.IAipDocument, client *http.Client) {
+	fmt.Println("Retrieve the En-route Data")
+	if _, err := doc.GetRoutes(client); err != nil {
+		doc.AddError(err)
+	}
+	if _, err := doc.GetAirspaces(client); err != nil {
+		doc.AddError(err)
+	}
+	if _, err := doc.GetRestrictedAreas(client); err != nil {
+		doc.AddError(err)
+	}
+	if _, err := doc.GetObstacles(client); err != nil {
+		doc.AddError(err)
+	}
+}
+
+func runExport(ais generic.CountryAis) (*generic.FailureReport, error) {
+	doc, client, err := loadActiveDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	loadEnrouteData(doc, client)
+	pth := exportPath
+	if pth == "" {
+		pth = filepath.Join(doc.DirMainDownload(), "export."+exportFormat)
+	}
+	if err := generic.Export(doc, exportFormat, pth); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Exported in %s \n", pth)
+	return generic.CollectFailures(doc), nil
+}
+
+// runVerify reports each missing or invalid file as a failure.
+func runVerify(ais generic.CountryAis) (*generic.FailureReport, error) {
+	doc, _, err := loadActiveDocument(ais)
+	if err != nil {
+		return nil, err
+	}
+	report := generic.CollectFailures(doc)
+	for _, err := range doc.VerifyAllAiportsData() {
+		report.Add(generic.Failure{Country: doc.Document().CountryCode, Err: err})
+	}
+	if report.Count() == 0 {
+		fmt.Println("All the files are present")
+	}
+	return report, nil
+}
@@ -2,18 +2,18 @@ package main
 
 import (
 	"fmt"
+	"os"
+
 	"github.com/NagoDede/aipdownloader/generic"
-	"github.com/NagoDede/aipdownloader/japan"
+	_ "github.com/NagoDede/aipdownloader/japan"
 )
 
 func main() {
 	generic.ConfData = generic.ConfigurationDataStruct{}
-	japan.JapanAis = japan.JpData{}
 	fmt.Println("AIP Downloader is starting")
-	generic.ConfData.LoadConfigurationFile("./aipdownloader.json")
-	fmt.Printf("Data will be stored in %s \n", generic.ConfData.MainLocalDir)
-
-	japan.JapanAis.LoadJsonFile("./japan.json")
-	japan.JapanAis.Process()
+	if err := runCli(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 	fmt.Println("AIP Downloader - End of process")
 }